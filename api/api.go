@@ -1,9 +1,12 @@
 package api
 
 import (
+	"context"
 	"crypto/md5"
 	"encoding/hex"
 	"fmt"
+	"path"
+	"reflect"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -22,10 +25,33 @@ type APIManager interface {
 	GetAPIs(tag string) map[string]string
 }
 
+// Route 注册的接口及其输入输出类型，用于生成OpenAPI文档
+type Route struct {
+	Hash   string // 与AddAPI记录的hash相同，按路由组内的相对路径计算，不同路由组中的相同路径hash相同
+	Tag    string
+	Method string
+	Path   string       // 完整路径，gin格式，如 /v1/users/:id
+	Input  reflect.Type // 输入结构体，没有输入时为nil
+	Output reflect.Type // 返回类型，只有macaron.Typed的handler能确定，否则为nil
+}
+
+// APIResolver 按请求查找接口，用于没有通过Handle注册的接口的鉴权，如网关转发的请求
+type APIResolver interface {
+	Resolve(method, path string) *Route
+}
+
+// RouteManager 记录接口输入输出类型的APIManager
+type RouteManager interface {
+	AddRoute(route *Route)
+	Routes() []*Route
+}
+
 type APIs struct {
 	apis       map[string]string
 	prefixApis map[string]struct{}
 	taggedApis map[string]map[string]string
+	routes     []*Route
+	fullPaths  map[string]*Route // apiKey(方法, 完整路径) -> Route，用于按请求路径鉴权
 }
 
 func NewAPIs() *APIs {
@@ -33,6 +59,7 @@ func NewAPIs() *APIs {
 		apis:       make(map[string]string),
 		prefixApis: make(map[string]struct{}),
 		taggedApis: make(map[string]map[string]string),
+		fullPaths:  make(map[string]*Route),
 	}
 }
 
@@ -41,8 +68,12 @@ func ApiHash(api string) string {
 	return hex.EncodeToString(m[:])[8:24]
 }
 
+func apiKey(method, path string) string {
+	return fmt.Sprintf("%s %s", strings.ToLower(method), strings.ToLower(path))
+}
+
 func (s *APIs) AddAPI(tag, method, path string) {
-	key := fmt.Sprintf("%s %s", strings.ToLower(method), strings.ToLower(path))
+	key := apiKey(method, path)
 	hash := ApiHash(key)
 	s.apis[hash] = key
 	if strings.HasSuffix(key, "*") {
@@ -62,17 +93,94 @@ func (s *APIs) GetAPIs(tag string) map[string]string {
 	return s.taggedApis[tag]
 }
 
+// Resolve 按请求的方法和路径查找接口：先按完整路径查找Handle注册的接口，
+// 再查找AddAPI注册的接口，没有完全相同的接口时匹配最长的前缀接口(路径以/*结尾)
+func (s *APIs) Resolve(method, path string) *Route {
+	key := apiKey(method, path)
+	if route, ok := s.fullPaths[key]; ok {
+		return route
+	}
+	hash := ApiHash(key)
+	if _, ok := s.apis[hash]; !ok {
+		hash = ""
+		for p := key; hash == ""; {
+			i := strings.LastIndex(p, "/")
+			if i < 0 {
+				return nil
+			}
+			p = p[:i]
+			if _, ok := s.prefixApis[p]; ok {
+				key = p + "/*"
+				hash = ApiHash(key)
+			}
+		}
+	}
+	route := &Route{Hash: hash, Method: strings.ToUpper(method)}
+	route.Path = key[strings.Index(key, " ")+1:]
+	for tag, apiSet := range s.taggedApis {
+		if _, ok := apiSet[hash]; ok {
+			route.Tag = tag
+			break
+		}
+	}
+	return route
+}
+
+func (s *APIs) AddRoute(route *Route) {
+	s.routes = append(s.routes, route)
+	s.fullPaths[apiKey(route.Method, route.Path)] = route
+}
+
+func (s *APIs) Routes() []*Route {
+	return s.routes
+}
+
 var DefaultAPIManager APIManager = NewAPIs()
 var DefaultMacaron *macaron.Macaron = NewMacaron()
 
 func NewMacaron() *macaron.Macaron {
 	m := macaron.New()
 	m.Map(middleware.HTTPResp())
+	m.Use(Authorize())
 	m.Use(middleware.Parse())
 	return m
 }
 
 func Handle(tag string, group *gin.RouterGroup, method, path string, handler macaron.Handler) {
+	// hash和GetAPIs的key仍按路由组内的相对路径计算，与已保存的hash兼容；
+	// Route.Path为完整路径，Resolve按完整路径查找，需要区分不同路由组的相同路径时使用"方法 完整路径"形式的权限
 	DefaultAPIManager.AddAPI(tag, method, path)
-	group.Handle(method, path, DefaultMacaron.Wraps(handler))
+	in, out := macaron.HandlerTypes(handler)
+	route := &Route{Hash: ApiHash(apiKey(method, path)), Tag: tag, Method: strings.ToUpper(method), Path: joinPaths(group.BasePath(), path), Input: in, Output: out}
+	if rm, ok := DefaultAPIManager.(RouteManager); ok {
+		rm.AddRoute(route)
+	}
+	group.Handle(method, path, withRoute(route), DefaultMacaron.Wraps(handler))
+}
+
+var ctxRouteKey = struct{ name string }{"api.route"}
+
+// withRoute 把接口信息放入请求的context，供鉴权等中间件使用
+func withRoute(route *Route) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Request = ctx.Request.WithContext(context.WithValue(ctx.Request.Context(), ctxRouteKey, route))
+	}
+}
+
+// RouteFromContext 返回Handle注册的当前请求的接口
+func RouteFromContext(ctx context.Context) *Route {
+	route, _ := ctx.Value(ctxRouteKey).(*Route)
+	return route
+}
+
+// joinPaths 与gin拼接路由组路径的方式相同，保留末尾的/
+func joinPaths(base, rel string) string {
+	if rel == "" {
+		return base
+	}
+	p := path.Join(base, rel)
+	if strings.HasSuffix(rel, "/") && !strings.HasSuffix(p, "/") {
+		p += "/"
+	}
+	return p
 }
@@ -0,0 +1,246 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hudangwei/common/logger"
+	"github.com/hudangwei/common/macaron"
+	"github.com/hudangwei/common/macaron/middleware"
+	"github.com/hudangwei/common/macaron/user"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrUnauthorized = macaron.NewError("error", "login required", http.StatusUnauthorized)
+	ErrForbidden    = macaron.NewError("error", "permission denied", http.StatusForbidden)
+)
+
+// PermissionSource 角色拥有的权限，每项权限为以下形式之一：
+//
+//	"*"                   所有接口
+//	"tag:team"            标签下的所有接口
+//	"3f6b1c0d9e2a4b5c"    ApiHash返回的接口hash
+//	"get /v1/users/:id"   方法和完整路径，方法为*时匹配所有方法，路径以/*结尾时匹配前缀
+//
+// hash按路由组内的相对路径计算，不同路由组中的相同路径hash相同，需要区分时使用方法和完整路径。
+// 8736ccd至此之间曾按完整路径计算hash，这期间保存的hash需按相对路径重新计算
+type PermissionSource interface {
+	Permissions(role string) ([]string, error)
+}
+
+// Authorizer 按用户的角色检查接口权限，PublicTags中标签的接口无需登录，
+// 其他接口需要登录，没有标签的接口登录即可访问
+type Authorizer struct {
+	Source     PermissionSource
+	PublicTags []string      // 默认为APITagGuest
+	CacheTTL   time.Duration // 角色权限在内存中的缓存时间，为0时每次请求都从Source读取
+
+	cache sync.Map // role -> *cachedPermissions
+}
+
+type cachedPermissions struct {
+	perms   []string
+	expires time.Time
+}
+
+// DefaultAuthorizer Authorize中间件使用的鉴权，为nil时不鉴权
+var DefaultAuthorizer *Authorizer
+
+func (a *Authorizer) isPublic(tag string) bool {
+	if a.PublicTags == nil {
+		return tag == APITagGuest
+	}
+	for _, t := range a.PublicTags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func isLogin(u *user.User) bool {
+	return u != nil && (u.Uid != 0 || u.UserId != "")
+}
+
+// Check 未登录返回ErrUnauthorized，没有权限返回ErrForbidden，读取权限失败时返回对应的错误
+func (a *Authorizer) Check(u *user.User, route *Route) error {
+	if a.isPublic(route.Tag) {
+		return nil
+	}
+	if !isLogin(u) {
+		return ErrUnauthorized
+	}
+	if route.Tag == "" {
+		return nil
+	}
+	perms, err := a.permissionsOf(u.Roles)
+	if err != nil {
+		return err
+	}
+	if !allowed(perms, route) {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// Invalidate 删除角色的权限缓存，没有参数时删除所有缓存
+func (a *Authorizer) Invalidate(roles ...string) {
+	if len(roles) == 0 {
+		a.cache.Range(func(k, _ interface{}) bool {
+			a.cache.Delete(k)
+			return true
+		})
+		return
+	}
+	for _, role := range roles {
+		a.cache.Delete(role)
+	}
+}
+
+func (a *Authorizer) permissionsOf(roles []string) ([]string, error) {
+	var perms []string
+	for _, role := range roles {
+		if a.CacheTTL > 0 {
+			if v, ok := a.cache.Load(role); ok && time.Now().Before(v.(*cachedPermissions).expires) {
+				perms = append(perms, v.(*cachedPermissions).perms...)
+				continue
+			}
+		}
+		if a.Source == nil {
+			continue
+		}
+		rolePerms, err := a.Source.Permissions(role)
+		if err != nil {
+			return nil, err
+		}
+		if a.CacheTTL > 0 {
+			a.cache.Store(role, &cachedPermissions{perms: rolePerms, expires: time.Now().Add(a.CacheTTL)})
+		}
+		perms = append(perms, rolePerms...)
+	}
+	return perms, nil
+}
+
+func allowed(perms []string, route *Route) bool {
+	key := apiKey(route.Method, route.Path)
+	for _, p := range perms {
+		p = strings.TrimSpace(p)
+		switch {
+		case p == "*":
+			return true
+		case strings.HasPrefix(p, "tag:"):
+			if p[len("tag:"):] == route.Tag {
+				return true
+			}
+		case !strings.Contains(p, " "):
+			if p == route.Hash {
+				return true
+			}
+		default:
+			if matchAPI(strings.ToLower(p), key) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchAPI pattern和key都是"方法 路径"形式
+func matchAPI(pattern, key string) bool {
+	pm, pp, _ := strings.Cut(pattern, " ")
+	km, kp, _ := strings.Cut(key, " ")
+	if pm != "*" && pm != km {
+		return false
+	}
+	pp = strings.TrimSpace(pp)
+	if strings.HasSuffix(pp, "/*") {
+		prefix := strings.TrimSuffix(pp, "*")
+		return kp == prefix[:len(prefix)-1] || strings.HasPrefix(kp, prefix)
+	}
+	return pp == kp
+}
+
+// Authorize 使用DefaultAuthorizer鉴权的中间件，已加入DefaultMacaron，在解析参数之前执行；
+// 不是通过Handle注册的接口由DefaultAPIManager按请求路径查找，仍找不到时只要求登录
+func Authorize() macaron.Handler {
+	return func(ctx *macaron.Context) int {
+		a := DefaultAuthorizer
+		if a == nil {
+			return 0
+		}
+		route := RouteFromContext(ctx.Req.Context())
+		if route == nil {
+			if r, ok := DefaultAPIManager.(APIResolver); ok {
+				route = r.Resolve(ctx.Req.Method, ctx.Req.URL.Path)
+			}
+		}
+		if route == nil {
+			route = &Route{Method: ctx.Req.Method, Path: ctx.Req.URL.Path}
+		}
+		err := a.Check(&ctx.User, route)
+		if err == nil {
+			return 0
+		}
+		var e *macaron.Error
+		if !errors.As(err, &e) {
+			logger.Error("load permissions with error", zap.Error(err), zap.Strings("roles", ctx.User.Roles))
+			e = macaron.NewError("error", "load permissions failed", http.StatusInternalServerError)
+		}
+		return middleware.AbortWith(ctx, e)
+	}
+}
+
+// APIInfo 接口信息，前端按hash或方法和路径判断是否显示对应的功能
+type APIInfo struct {
+	Hash   string `json:"hash"`
+	Tag    string `json:"tag"`
+	Method string `json:"method"`
+	Path   string `json:"path"`
+}
+
+type AvailableAPIs struct {
+	Apis []*APIInfo `json:"apis"`
+}
+
+// AvailableAPIs 返回u可以访问的Handle注册的接口，按路径和方法排序
+func (a *Authorizer) AvailableAPIs(u *user.User) ([]*APIInfo, error) {
+	rm, ok := DefaultAPIManager.(RouteManager)
+	if !ok {
+		return nil, nil
+	}
+	var apis []*APIInfo
+	for _, route := range rm.Routes() {
+		if a != nil {
+			if err := a.Check(u, route); errors.Is(err, ErrUnauthorized) || errors.Is(err, ErrForbidden) {
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+		}
+		apis = append(apis, &APIInfo{Hash: route.Hash, Tag: route.Tag, Method: route.Method, Path: route.Path})
+	}
+	sort.Slice(apis, func(i, j int) bool {
+		if apis[i].Path != apis[j].Path {
+			return apis[i].Path < apis[j].Path
+		}
+		return apis[i].Method < apis[j].Method
+	})
+	return apis, nil
+}
+
+// HandleAvailableAPIs 注册 GET path，返回当前用户可以访问的接口
+func HandleAvailableAPIs(tag string, group *gin.RouterGroup, path string) {
+	Handle(tag, group, http.MethodGet, path, macaron.Typed(func(ctx *macaron.Context, _ *struct{}) (*AvailableAPIs, error) {
+		apis, err := DefaultAuthorizer.AvailableAPIs(&ctx.User)
+		if err != nil {
+			return nil, err
+		}
+		return &AvailableAPIs{Apis: apis}, nil
+	}))
+}
@@ -0,0 +1,156 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+	"github.com/gin-gonic/gin"
+	"github.com/hudangwei/common/macaron"
+	"github.com/hudangwei/common/macaron/user"
+)
+
+type tomlConfig string
+
+func (c tomlConfig) LoadConfig(config interface{}, name string) (interface{}, error) {
+	var sections map[string]toml.Primitive
+	md, err := toml.Decode(string(c), &sections)
+	if err != nil {
+		return nil, err
+	}
+	return config, md.PrimitiveDecode(sections[name], config)
+}
+
+const permissionsConfig = `
+[permissions.roles]
+admin = ["*"]
+member = ["tag:team", "get /v1/reports/*"]
+`
+
+func TestAuthorize(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	defer func(m APIManager, a *Authorizer) { DefaultAPIManager, DefaultAuthorizer = m, a }(DefaultAPIManager, DefaultAuthorizer)
+	DefaultAPIManager = NewAPIs()
+
+	perms, err := LoadStaticPermissions(tomlConfig(permissionsConfig), "permissions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	DefaultAuthorizer = &Authorizer{Source: perms}
+
+	ok := func(ctx *macaron.Context) interface{} { return macaron.OK }
+	r := gin.New()
+	r.Use(func(ctx *gin.Context) {
+		if role := ctx.GetHeader("X-Role"); role != "" {
+			u := &user.User{Uid: 1, Roles: []string{role}}
+			ctx.Request = ctx.Request.WithContext(user.NewContextWithUser(ctx.Request.Context(), u))
+		}
+	})
+	g := r.Group("/v1")
+	Handle(APITagGuest, g, http.MethodGet, "/public", ok)
+	Handle("", g, http.MethodGet, "/profile", ok)
+	Handle(APITagTeam, g, http.MethodGet, "/team", ok)
+	Handle(APITagDeveloper, g, http.MethodPost, "/apps", ok)
+	Handle(APITagDeveloper, g, http.MethodGet, "/reports/:id", ok)
+	HandleAvailableAPIs("", g, "/apis")
+
+	cases := []struct {
+		role, method, path string
+		status             int
+	}{
+		{"", http.MethodGet, "/v1/public", http.StatusOK},
+		{"", http.MethodGet, "/v1/profile", http.StatusUnauthorized},
+		{"", http.MethodGet, "/v1/team", http.StatusUnauthorized},
+		{"guest", http.MethodGet, "/v1/profile", http.StatusOK},
+		{"guest", http.MethodGet, "/v1/team", http.StatusForbidden},
+		{"member", http.MethodGet, "/v1/team", http.StatusOK},
+		{"member", http.MethodPost, "/v1/apps", http.StatusForbidden},
+		{"member", http.MethodGet, "/v1/reports/1", http.StatusOK},
+		{"admin", http.MethodPost, "/v1/apps", http.StatusOK},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(c.method, c.path, nil)
+		req.Header.Set("X-Role", c.role)
+		r.ServeHTTP(w, req)
+		if w.Code != c.status {
+			t.Errorf("%s %s %s: status = %d, want %d, body %s", c.role, c.method, c.path, w.Code, c.status, w.Body)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/v1/apis", nil)
+	req.Header.Set("X-Role", "member")
+	r.ServeHTTP(w, req)
+	var resp AvailableAPIs
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err, w.Body)
+	}
+	var paths []string
+	for _, api := range resp.Apis {
+		paths = append(paths, api.Method+" "+api.Path)
+	}
+	want := []string{"GET /v1/apis", "GET /v1/profile", "GET /v1/public", "GET /v1/reports/:id", "GET /v1/team"}
+	if len(paths) != len(want) {
+		t.Fatalf("apis = %v, want %v", paths, want)
+	}
+	for i := range want {
+		if paths[i] != want[i] {
+			t.Errorf("apis = %v, want %v", paths, want)
+			break
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	apis := NewAPIs()
+	apis.AddAPI(APITagTeam, "GET", "/proxy/*")
+	apis.AddAPI(APITagGuest, "GET", "/proxy/public")
+
+	if route := apis.Resolve("GET", "/proxy/public"); route == nil || route.Tag != APITagGuest {
+		t.Errorf("route = %+v", route)
+	}
+	route := apis.Resolve("GET", "/proxy/a/b")
+	if route == nil || route.Tag != APITagTeam || route.Hash != ApiHash("get /proxy/*") || route.Path != "/proxy/*" {
+		t.Errorf("route = %+v", route)
+	}
+	if route := apis.Resolve("POST", "/proxy/a"); route != nil {
+		t.Errorf("route = %+v", route)
+	}
+	if !allowed([]string{"get /proxy/*"}, route) || allowed([]string{"post /proxy/*"}, route) || !allowed([]string{route.Hash}, route) {
+		t.Error("allowed")
+	}
+}
+
+func TestHandleGroupRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	defer func(m APIManager) { DefaultAPIManager = m }(DefaultAPIManager)
+	apis := NewAPIs()
+	DefaultAPIManager = apis
+
+	ok := func(ctx *macaron.Context) interface{} { return macaron.OK }
+	r := gin.New()
+	Handle(APITagTeam, r.Group("/v1"), http.MethodGet, "/items", ok)
+	Handle(APITagDeveloper, r.Group("/v2"), http.MethodGet, "/items", ok)
+
+	// hash按相对路径计算，与之前保存的hash兼容
+	hash := ApiHash("get /items")
+	routes := apis.Routes()
+	if len(routes) != 2 || routes[0].Hash != hash || routes[1].Hash != hash {
+		t.Fatalf("routes = %+v %+v", routes[0], routes[1])
+	}
+	if apis.GetAPIs(APITagTeam)[hash] != "get /items" {
+		t.Errorf("apis = %v", apis.GetAPIs(APITagTeam))
+	}
+	// 鉴权按完整路径找到各自的接口
+	for _, want := range routes {
+		if got := apis.Resolve(http.MethodGet, want.Path); got != want {
+			t.Errorf("resolve %s: %+v, want %+v", want.Path, got, want)
+		}
+	}
+	if got := apis.Resolve(http.MethodGet, "/items"); got == nil || got.Hash != hash {
+		t.Errorf("resolve /items: %+v", got)
+	}
+}
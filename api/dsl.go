@@ -0,0 +1,86 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hudangwei/common/macaron"
+	"github.com/hudangwei/common/mongo/dsl"
+)
+
+type DslInput struct {
+	Query  string `auto_read:"q"`
+	Cursor *int   `auto_read:"cursor"` // 光标位置(字符偏移)，为空时取语句末尾
+}
+
+// DslCompleteHandler 搜索框的自动补全和语句校验，返回dsl.Completion；语句违反policy时返回400
+func DslCompleteHandler(configs []dsl.Config, policy *dsl.Policy) macaron.Handler {
+	return func(ctx *macaron.Context, input *DslInput) interface{} {
+		p := userPolicy(ctx, policy)
+		// 无权限的字段不参与补全，查询时按未知字段报错
+		allowed := dsl.FilterConfigs(configs, p)
+		// 输入中的语句可能还不完整，只检查能解析的语句
+		if rule, err := dsl.Parse(input.Query); err == nil {
+			if e := policyError(rule.Check(allowed, p)); e != nil {
+				return e
+			}
+		}
+		cursor := -1
+		if input.Cursor != nil {
+			cursor = *input.Cursor
+		}
+		return dsl.Complete(input.Query, cursor, allowed)
+	}
+}
+
+// DslExplainHandler 返回规范化的查询语句和生成的Mongo/SQL过滤条件，返回dsl.Explanation
+func DslExplainHandler(configs []dsl.Config, policy *dsl.Policy) macaron.Handler {
+	return func(ctx *macaron.Context, input *DslInput) interface{} {
+		p := userPolicy(ctx, policy)
+		allowed := dsl.FilterConfigs(configs, p)
+		rule, err := dsl.Parse(input.Query)
+		if err != nil {
+			return macaron.NewError("error", err.Error(), http.StatusBadRequest)
+		}
+		if err := rule.Check(allowed, p); err != nil {
+			if e := policyError(err); e != nil {
+				return e
+			}
+			return macaron.NewError("error", err.Error(), http.StatusBadRequest)
+		}
+		e, err := dsl.Explain(input.Query, allowed)
+		if err != nil {
+			return macaron.NewError("error", err.Error(), http.StatusBadRequest)
+		}
+		return e
+	}
+}
+
+// HandleDsl 注册 GET path/complete?q=&cursor= 和 GET path/explain?q=，
+// policy为代价限制，为nil时不限制；Roles按请求的用户设置
+func HandleDsl(tag string, group *gin.RouterGroup, path string, configs []dsl.Config, policy *dsl.Policy) {
+	Handle(tag, group, http.MethodGet, path+"/complete", DslCompleteHandler(configs, policy))
+	Handle(tag, group, http.MethodGet, path+"/explain", DslExplainHandler(configs, policy))
+}
+
+// userPolicy 复制policy并设置为当前用户的角色
+func userPolicy(ctx *macaron.Context, policy *dsl.Policy) *dsl.Policy {
+	p := &dsl.Policy{}
+	if policy != nil {
+		*p = *policy
+	}
+	p.Roles = ctx.User.Roles
+	return p
+}
+
+// policyError *dsl.PolicyError转为带详情的400响应，其他错误返回nil
+func policyError(err error) *macaron.Error {
+	var pe *dsl.PolicyError
+	if !errors.As(err, &pe) {
+		return nil
+	}
+	e := macaron.NewError("error", pe.Error(), http.StatusBadRequest)
+	e.Details = pe
+	return e
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hudangwei/common/mongo/dsl"
+)
+
+func TestHandleDsl(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	defer func(m APIManager, a *Authorizer) { DefaultAPIManager, DefaultAuthorizer = m, a }(DefaultAPIManager, DefaultAuthorizer)
+	DefaultAPIManager = NewAPIs()
+	DefaultAuthorizer = &Authorizer{}
+
+	configs := []dsl.Config{
+		{TagName: "name", ColumnName: "name", Type: dsl.ConfigTypeString, Regex: true},
+		{TagName: "age", ColumnName: "age", Type: dsl.ConfigTypeNumber},
+	}
+	r := gin.New()
+	HandleDsl(APITagGuest, r.Group("/"), "/dsl", configs, &dsl.Policy{MaxClauses: 2, MaxRegex: -1, MaxInValues: 2})
+
+	cases := []struct {
+		path, query string
+		status      int
+		rule        string
+	}{
+		{"/dsl/explain", `name="a" && age>1`, http.StatusOK, ""},
+		{"/dsl/explain", `name="a" && age>1 && age<9`, http.StatusBadRequest, dsl.PolicyMaxClauses},
+		{"/dsl/explain", `name~="^a"`, http.StatusBadRequest, dsl.PolicyMaxRegex},
+		{"/dsl/complete", `age in (1,2,3)`, http.StatusBadRequest, dsl.PolicyMaxInValues},
+		{"/dsl/complete", `name="a" && a`, http.StatusOK, ""},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, c.path+"?q="+url.QueryEscape(c.query), nil))
+		if w.Code != c.status {
+			t.Errorf("%s %s: status = %d, want %d, body %s", c.path, c.query, w.Code, c.status, w.Body)
+			continue
+		}
+		if c.rule == "" {
+			continue
+		}
+		var resp struct {
+			Details dsl.PolicyError `json:"details"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Details.Rule != c.rule {
+			t.Errorf("%s %s: body %s", c.path, c.query, w.Body)
+		}
+	}
+}
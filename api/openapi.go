@@ -0,0 +1,41 @@
+package api
+
+import (
+	"net/http"
+	"path"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hudangwei/common/api/openapi"
+)
+
+// OpenAPI 根据Handle注册的接口生成OpenAPI 3文档，DefaultAPIManager需要实现RouteManager
+func OpenAPI(info openapi.Info) *openapi.Document {
+	b := openapi.NewBuilder(info)
+	if rm, ok := DefaultAPIManager.(RouteManager); ok {
+		for _, r := range rm.Routes() {
+			b.Add(openapi.Endpoint{ID: r.Hash, Tag: r.Tag, Method: r.Method, Path: r.Path, Input: r.Input, Output: r.Output})
+		}
+	}
+	return b.Document()
+}
+
+// HandleOpenAPI 注册 GET path 的Swagger UI页面、GET path/openapi.json 的文档和 GET path/assets/ 的静态文件，
+// 文档每次请求时生成，包含之后注册的接口；这些接口本身不出现在文档中
+func HandleOpenAPI(group *gin.RouterGroup, uiPath string, info openapi.Info) {
+	specPath := path.Join(uiPath, "openapi.json")
+	specURL := joinPaths(group.BasePath(), specPath)
+	assetsPath := path.Join(uiPath, "assets")
+	assetsURL := joinPaths(group.BasePath(), assetsPath)
+	group.StaticFS(assetsPath, http.FS(openapi.SwaggerAssets))
+	group.GET(specPath, func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, OpenAPI(info))
+	})
+	group.GET(uiPath, func(ctx *gin.Context) {
+		page, err := openapi.SwaggerUI(info.Title, specURL, assetsURL)
+		if err != nil {
+			ctx.String(http.StatusInternalServerError, err.Error())
+			return
+		}
+		ctx.Data(http.StatusOK, "text/html; charset=utf-8", page)
+	})
+}
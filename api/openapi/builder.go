@@ -0,0 +1,227 @@
+package openapi
+
+import (
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hudangwei/common/binding"
+	"github.com/hudangwei/common/macaron"
+)
+
+// Endpoint 一个接口，Input和Output为空时只生成路径参数和默认响应
+type Endpoint struct {
+	ID     string // operationId
+	Tag    string
+	Method string
+	Path   string // gin格式的路径，如 /users/:id
+	Input  reflect.Type
+	Output reflect.Type
+}
+
+// Builder 逐个添加接口生成文档，结构体类型只生成一次，放在components.schemas中
+type Builder struct {
+	doc   *Document
+	types map[reflect.Type]string
+	names map[string]reflect.Type
+	ids   map[string]bool
+	tags  map[string]bool
+}
+
+func NewBuilder(info Info) *Builder {
+	return &Builder{
+		doc: &Document{
+			OpenAPI:    "3.0.3",
+			Info:       info,
+			Paths:      map[string]*PathItem{},
+			Components: Components{Schemas: map[string]*Schema{}},
+		},
+		types: map[reflect.Type]string{},
+		names: map[string]reflect.Type{},
+		ids:   map[string]bool{},
+		tags:  map[string]bool{},
+	}
+}
+
+// Document 返回生成的文档，之后不应再调用Add
+func (b *Builder) Document() *Document {
+	names := make([]string, 0, len(b.tags))
+	for name := range b.tags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	b.doc.Tags = b.doc.Tags[:0]
+	for _, name := range names {
+		b.doc.Tags = append(b.doc.Tags, Tag{Name: name})
+	}
+	return b.doc
+}
+
+var errorType = reflect.TypeOf(macaron.Error{})
+
+func (b *Builder) Add(e Endpoint) {
+	path, pathParams := convertPath(e.Path)
+	op := &Operation{
+		OperationID: b.operationID(e),
+		Responses:   map[string]*Response{},
+	}
+	if e.Tag != "" {
+		op.Tags = []string{e.Tag}
+		b.tags[e.Tag] = true
+	}
+
+	declared := map[string]bool{}
+	if e.Input != nil {
+		declared = b.addInput(op, e.Method, e.Input)
+	}
+	// handler中通过PathParamsFunc读取，输入结构体中没有声明的路径参数
+	for _, name := range pathParams {
+		if !declared[name] {
+			op.Parameters = append(op.Parameters, &Parameter{Name: name, In: "path", Required: true, Schema: &Schema{Type: "string"}})
+		}
+	}
+
+	ok := &Response{Description: http.StatusText(http.StatusOK)}
+	if e.Output != nil {
+		ok.Content = map[string]*MediaType{"application/json": {Schema: b.schemaOf(e.Output)}}
+	}
+	op.Responses[strconv.Itoa(http.StatusOK)] = ok
+	errResp := &Response{
+		Description: "error",
+		Content:     map[string]*MediaType{"application/json": {Schema: b.schemaOf(errorType)}},
+	}
+	if e.Input != nil {
+		op.Responses[strconv.Itoa(http.StatusBadRequest)] = &Response{Description: "invalid input", Content: errResp.Content}
+	}
+	op.Responses["default"] = errResp
+
+	item, exists := b.doc.Paths[path]
+	if !exists {
+		item = &PathItem{}
+		b.doc.Paths[path] = item
+	}
+	(*item)[strings.ToLower(e.Method)] = op
+}
+
+// operationID 为空或重复时加上序号
+func (b *Builder) operationID(e Endpoint) string {
+	id := e.ID
+	if id == "" {
+		id = strings.ToLower(e.Method) + componentName(strings.ReplaceAll(e.Path, "/", "_"))
+	}
+	for i, base := 2, id; b.ids[id]; i++ {
+		id = base + "_" + strconv.Itoa(i)
+	}
+	b.ids[id] = true
+	return id
+}
+
+// convertPath 把gin的 :id 和 *file 转换为OpenAPI的 {id} 和 {file}
+func convertPath(path string) (string, []string) {
+	var params []string
+	parts := strings.Split(path, "/")
+	for i, part := range parts {
+		if len(part) > 1 && (part[0] == ':' || part[0] == '*') {
+			params = append(params, part[1:])
+			parts[i] = "{" + part[1:] + "}"
+		}
+	}
+	return strings.Join(parts, "/"), params
+}
+
+func isBodyMethod(method string) bool {
+	switch strings.ToUpper(method) {
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+		return true
+	}
+	return false
+}
+
+// addInput 按binding的规则生成参数和请求体：path、header来源的字段为对应参数，
+// 其他字段GET等方法为query参数，POST/PUT/PATCH为请求体；返回声明的路径参数
+func (b *Builder) addInput(op *Operation, method string, t reflect.Type) map[string]bool {
+	declared := map[string]bool{}
+	bodyMethod := isBodyMethod(method)
+	var bodyFields []binding.Field
+	hasFile, hasRaw := false, false
+	for _, f := range binding.Fields(t) {
+		switch {
+		case f.Source == "path" || f.Source == "header":
+			s := b.paramSchema(f.StructField)
+			required := applyRules(s, f.StructField)
+			if f.Source == "path" {
+				declared[f.Name] = true
+				required = true
+			}
+			op.Parameters = append(op.Parameters, &Parameter{Name: f.Name, In: f.Source, Required: required, Schema: s})
+		case !bodyMethod:
+			if f.File || f.Name == "@body" {
+				continue
+			}
+			s := b.paramSchema(f.StructField)
+			p := &Parameter{Name: f.Name, In: "query", Required: applyRules(s, f.StructField), Schema: s}
+			if s.Type == "object" {
+				explode := true
+				p.Style, p.Explode = "deepObject", &explode
+			}
+			op.Parameters = append(op.Parameters, p)
+		case f.Name == "@body":
+			hasRaw = true
+		default:
+			hasFile = hasFile || f.File
+			bodyFields = append(bodyFields, f)
+		}
+	}
+	if !bodyMethod {
+		return declared
+	}
+
+	body := &RequestBody{Content: map[string]*MediaType{}}
+	if len(bodyFields) > 0 {
+		form := &Schema{Type: "object", Properties: map[string]*Schema{}}
+		for _, f := range bodyFields {
+			var s *Schema
+			if f.File {
+				s = b.schemaOf(f.Type)
+			} else {
+				s = b.paramSchema(f.StructField)
+			}
+			if applyRules(s, f.StructField) {
+				form.Required = append(form.Required, f.Name)
+			}
+			form.Properties[f.Name] = s
+		}
+		body.Required = len(form.Required) > 0
+		if hasFile {
+			body.Content["multipart/form-data"] = &MediaType{Schema: form}
+		} else {
+			skipped := map[string]bool{}
+			for _, f := range binding.Fields(t) {
+				if f.Source != "" || f.File || f.Name == "@body" {
+					skipped[indexKey(f.Index)] = true
+				}
+			}
+			s := &Schema{Type: "object"}
+			b.structSchema(s, t, nil, func(index []int) bool { return skipped[indexKey(index)] })
+			body.Content["application/json"] = &MediaType{Schema: s}
+			body.Content["application/x-www-form-urlencoded"] = &MediaType{Schema: form}
+		}
+	} else if hasRaw {
+		body.Content["application/octet-stream"] = &MediaType{Schema: &Schema{Type: "string", Format: "binary"}}
+	}
+	if len(body.Content) > 0 {
+		op.RequestBody = body
+	}
+	return declared
+}
+
+func indexKey(index []int) string {
+	var sb strings.Builder
+	for _, i := range index {
+		sb.WriteString(strconv.Itoa(i))
+		sb.WriteByte('.')
+	}
+	return sb.String()
+}
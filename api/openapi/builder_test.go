@@ -0,0 +1,144 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hudangwei/common/binding"
+)
+
+type Page struct {
+	Offset int `auto_read:"offset" validate:"gte=0"`
+	Limit  int `auto_read:"limit" valid:"Range(1, 100)"`
+}
+
+type listInput struct {
+	Page
+	Keyword string            `auto_read:"q"`
+	Ids     []int64           `auto_read:"ids" validate:"max=10"`
+	Since   time.Time         `auto_read:"since" layout:"unix"`
+	Labels  map[string]string `auto_read:"labels"`
+	Token   string            `auto_read:"X-Token,header" validate:"required"`
+}
+
+type Address struct {
+	City string `json:"city" validate:"required"`
+}
+
+type User struct {
+	Id      int64     `json:"id,string"`
+	Name    string    `json:"name" validate:"required,min=2,max=20"`
+	Kind    string    `json:"kind" validate:"oneof=a b"`
+	Tags    []string  `json:"tags" validate:"dive,max=8"`
+	Address *Address  `json:"address"`
+	Friends []*User   `json:"friends,omitempty"`
+	Created time.Time `json:"created"`
+	secret  string
+}
+
+type updateInput struct {
+	Id    int64  `auto_read:"id,path" json:"-"`
+	Name  string `auto_read:"name" json:"name" valid:"Required;MaxSize(20)"`
+	Email string `auto_read:"email" json:"email" validate:"omitempty,email"`
+}
+
+type uploadInput struct {
+	Title  string                  `auto_read:"title" validate:"required"`
+	Avatar *binding.UploadedFile   `auto_read:"avatar"`
+	Photos []*binding.UploadedFile `auto_read:"photos"`
+}
+
+func TestBuilder(t *testing.T) {
+	b := NewBuilder(Info{Title: "test", Version: "1.0"})
+	b.Add(Endpoint{Tag: "guest", Method: "GET", Path: "/v1/users", Input: reflect.TypeOf(listInput{}), Output: reflect.TypeOf([]*User{})})
+	b.Add(Endpoint{ID: "update", Tag: "team", Method: "PUT", Path: "/v1/users/:id", Input: reflect.TypeOf(updateInput{}), Output: reflect.TypeOf(User{})})
+	b.Add(Endpoint{ID: "update", Tag: "team", Method: "POST", Path: "/v1/users/:id/avatar/*file", Input: reflect.TypeOf(uploadInput{})})
+	doc := b.Document()
+
+	if _, err := json.Marshal(doc); err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Tags) != 2 || doc.Tags[0].Name != "guest" || doc.Tags[1].Name != "team" {
+		t.Errorf("tags = %+v", doc.Tags)
+	}
+
+	list := (*doc.Paths["/v1/users"])["get"]
+	params := map[string]*Parameter{}
+	for _, p := range list.Parameters {
+		params[p.Name] = p
+	}
+	if p := params["offset"]; p == nil || p.In != "query" || *p.Schema.Minimum != 0 {
+		t.Errorf("offset = %+v", p)
+	}
+	if p := params["limit"]; p == nil || *p.Schema.Minimum != 1 || *p.Schema.Maximum != 100 {
+		t.Errorf("limit = %+v", p)
+	}
+	if p := params["ids"]; p == nil || p.Schema.Type != "array" || p.Schema.Items.Type != "integer" || *p.Schema.MaxItems != 10 {
+		t.Errorf("ids = %+v", p)
+	}
+	if p := params["since"]; p == nil || p.Schema.Type != "integer" {
+		t.Errorf("since = %+v", p)
+	}
+	if p := params["labels"]; p == nil || p.Style != "deepObject" || p.Schema.AdditionalProperties.Type != "string" {
+		t.Errorf("labels = %+v", p)
+	}
+	if p := params["X-Token"]; p == nil || p.In != "header" || !p.Required {
+		t.Errorf("X-Token = %+v", p)
+	}
+	resp := list.Responses["200"].Content["application/json"].Schema
+	if resp.Type != "array" || resp.Items.Ref != "#/components/schemas/User" {
+		t.Errorf("list response = %+v", resp)
+	}
+
+	user := doc.Components.Schemas["User"]
+	if user == nil {
+		t.Fatalf("components = %+v", doc.Components.Schemas)
+	}
+	if s := user.Properties["id"]; s.Type != "string" {
+		t.Errorf("id = %+v", s)
+	}
+	if s := user.Properties["name"]; *s.MinLength != 2 || *s.MaxLength != 20 {
+		t.Errorf("name = %+v", s)
+	}
+	if s := user.Properties["kind"]; !reflect.DeepEqual(s.Enum, []interface{}{"a", "b"}) {
+		t.Errorf("kind = %+v", s)
+	}
+	if s := user.Properties["tags"]; s.Items.MaxLength == nil || *s.Items.MaxLength != 8 {
+		t.Errorf("tags = %+v", s)
+	}
+	if s := user.Properties["friends"]; s.Items.Ref != "#/components/schemas/User" {
+		t.Errorf("friends = %+v", s)
+	}
+	if s := user.Properties["created"]; s.Format != "date-time" {
+		t.Errorf("created = %+v", s)
+	}
+	if _, ok := user.Properties["secret"]; ok || !reflect.DeepEqual(user.Required, []string{"name"}) {
+		t.Errorf("user = %+v", user)
+	}
+	if doc.Components.Schemas["Address"] == nil || doc.Components.Schemas["Error"] == nil {
+		t.Errorf("components = %+v", doc.Components.Schemas)
+	}
+
+	update := (*doc.Paths["/v1/users/{id}"])["put"]
+	if update.OperationID != "update" || len(update.Parameters) != 1 || update.Parameters[0].In != "path" {
+		t.Errorf("update = %+v", update)
+	}
+	body := update.RequestBody.Content["application/json"].Schema
+	if _, ok := body.Properties["id"]; ok || len(body.Properties) != 2 || body.Properties["email"].Format != "email" {
+		t.Errorf("update body = %+v", body)
+	}
+	if !update.RequestBody.Required || update.RequestBody.Content["application/x-www-form-urlencoded"] == nil {
+		t.Errorf("update body = %+v", update.RequestBody)
+	}
+
+	upload := (*doc.Paths["/v1/users/{id}/avatar/{file}"])["post"]
+	if upload.OperationID != "update_2" || len(upload.Parameters) != 2 {
+		t.Errorf("upload = %+v", upload)
+	}
+	form := upload.RequestBody.Content["multipart/form-data"].Schema
+	if form.Properties["avatar"].Format != "binary" || form.Properties["photos"].Items.Format != "binary" || form.Required[0] != "title" {
+		t.Errorf("upload form = %+v", form)
+	}
+}
@@ -0,0 +1,376 @@
+package openapi
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hudangwei/common/binding"
+)
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	durationType      = reflect.TypeOf(time.Duration(0))
+	fileType          = reflect.TypeOf(binding.UploadedFile{})
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// schemaOf t的json格式，命名的结构体放入components并返回引用
+func (b *Builder) schemaOf(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == fileType:
+		return &Schema{Type: "string", Format: "binary"}
+	case implements(t, jsonMarshalerType):
+		// 自定义的json格式无法确定
+		return &Schema{}
+	case implements(t, textMarshalerType):
+		return &Schema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: b.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: b.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			s := &Schema{Type: "object"}
+			b.structSchema(s, t, nil, nil)
+			return s
+		}
+		return &Schema{Ref: "#/components/schemas/" + b.component(t)}
+	}
+	// interface{}等任意类型
+	return &Schema{}
+}
+
+func implements(t, iface reflect.Type) bool {
+	return t.Implements(iface) || reflect.PtrTo(t).Implements(iface)
+}
+
+// component 返回结构体在components中的名字，首次出现时生成schema
+func (b *Builder) component(t reflect.Type) string {
+	if name, ok := b.types[t]; ok {
+		return name
+	}
+	name := componentName(t.Name())
+	if _, ok := b.names[name]; ok {
+		pkg := t.PkgPath()
+		name = componentName(pkg[strings.LastIndex(pkg, "/")+1:] + "." + t.Name())
+		for i, base := 2, name; ; i++ {
+			if _, ok := b.names[name]; !ok {
+				break
+			}
+			name = base + strconv.Itoa(i)
+		}
+	}
+	b.types[t] = name
+	b.names[name] = t
+
+	// 先占位，结构体引用自身时不会无限递归
+	s := &Schema{Type: "object"}
+	b.doc.Components.Schemas[name] = s
+	b.structSchema(s, t, nil, nil)
+	return name
+}
+
+var invalidNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// componentName 泛型类型的名字中有[]等字符，替换为_
+func componentName(name string) string {
+	return strings.Trim(invalidNameChars.ReplaceAllString(name, "_"), "_")
+}
+
+// structSchema 按encoding/json的规则生成结构体的属性，嵌入结构体的字段展开；
+// skip不为空时跳过返回true的字段，index为t在根结构体中的字段路径
+func (b *Builder) structSchema(s *Schema, t reflect.Type, index []int, skip func(index []int) bool) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		fieldIndex := append(append([]int{}, index...), i)
+		if skip != nil && skip(fieldIndex) {
+			continue
+		}
+		name, opts := sf.Name, ""
+		if tag, ok := sf.Tag.Lookup("json"); ok {
+			if tag == "-" {
+				continue
+			}
+			name, opts, _ = strings.Cut(tag, ",")
+			if name == "" {
+				name = sf.Name
+			}
+		}
+		ft := sf.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if sf.Anonymous {
+			if _, named := sf.Tag.Lookup("json"); !named && ft.Kind() == reflect.Struct {
+				b.structSchema(s, ft, fieldIndex, skip)
+				continue
+			}
+		}
+		if sf.PkgPath != "" {
+			continue
+		}
+
+		fs := b.schemaOf(sf.Type)
+		if strings.Contains(opts, "string") && fs.Ref == "" && (fs.Type == "integer" || fs.Type == "number" || fs.Type == "boolean") {
+			fs = &Schema{Type: "string"}
+		}
+		if applyRules(fs, sf) {
+			s.Required = append(s.Required, name)
+		}
+		if s.Properties == nil {
+			s.Properties = map[string]*Schema{}
+		}
+		s.Properties[name] = fs
+	}
+}
+
+// paramSchema 按binding的规则从字符串解析的参数，time.Time按layout标签，time.Duration为字符串
+func (b *Builder) paramSchema(sf reflect.StructField) *Schema {
+	t := sf.Type
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	var s *Schema
+	switch {
+	case t == timeType:
+		switch layout := sf.Tag.Get("layout"); layout {
+		case "unix", "unixmilli":
+			s = &Schema{Type: "integer", Format: "int64", Description: "timestamp(" + layout + ")"}
+		case "":
+			s = &Schema{Type: "string", Format: "date-time"}
+		default:
+			s = &Schema{Type: "string", Description: "layout: " + layout}
+		}
+	case t == durationType:
+		s = &Schema{Type: "string", Format: "duration"}
+	case t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8 && t.Elem() != timeType:
+		item := sf
+		item.Type = t.Elem()
+		s = &Schema{Type: "array", Items: b.paramSchema(item)}
+	case t.Kind() == reflect.Map:
+		item := sf
+		item.Type = t.Elem()
+		s = &Schema{Type: "object", AdditionalProperties: b.paramSchema(item)}
+	case t.Kind() == reflect.Slice:
+		s = &Schema{Type: "string"}
+	default:
+		s = b.schemaOf(t)
+	}
+	return s
+}
+
+// applyRules 把validate和valid标签中的规则转换为schema的约束，返回字段是否必填
+func applyRules(s *Schema, sf reflect.StructField) (required bool) {
+	if tag := sf.Tag.Get("validate"); tag != "" {
+		target := s
+		for _, rule := range strings.Split(tag, ",") {
+			if rule == "dive" {
+				// dive之后的规则作用于元素
+				target = target.Items
+				if target == nil {
+					break
+				}
+				continue
+			}
+			if strings.Contains(rule, "|") {
+				continue
+			}
+			name, param, _ := strings.Cut(rule, "=")
+			if name == "required" && target == s {
+				required = true
+				continue
+			}
+			applyValidate(target, name, param)
+		}
+	}
+	if tag := sf.Tag.Get("valid"); tag != "" {
+		rules, err := binding.ValidRules(tag)
+		if err != nil {
+			return required
+		}
+		for _, r := range rules {
+			if r.Name == "Required" {
+				required = true
+				continue
+			}
+			applyValid(s, r.Name, r.Args)
+		}
+	}
+	return required
+}
+
+var formatRules = map[string]string{
+	"email":    "email",
+	"url":      "uri",
+	"uri":      "uri",
+	"uuid":     "uuid",
+	"uuid4":    "uuid",
+	"ipv4":     "ipv4",
+	"ipv6":     "ipv6",
+	"hostname": "hostname",
+}
+
+var patternRules = map[string]string{
+	"alpha":        `^[a-zA-Z]+$`,
+	"alphanum":     `^[a-zA-Z0-9]+$`,
+	"numeric":      `^[-+]?[0-9]+(?:\.[0-9]+)?$`,
+	"Alpha":        `^[a-zA-Z]*$`,
+	"Numeric":      `^[0-9]*$`,
+	"AlphaNumeric": `^[a-zA-Z0-9]*$`,
+	"AlphaDash":    `^[\w-]*$`,
+	"ZipCode":      `^[1-9]\d{5}$`,
+}
+
+func applyValidate(s *Schema, name, param string) {
+	if s.Ref != "" {
+		return
+	}
+	if format, ok := formatRules[name]; ok {
+		s.Format = format
+		return
+	}
+	if pattern, ok := patternRules[name]; ok {
+		s.Pattern = pattern
+		return
+	}
+	switch name {
+	case "min", "gte":
+		setLower(s, param, false)
+	case "gt":
+		setLower(s, param, true)
+	case "max", "lte":
+		setUpper(s, param, false)
+	case "lt":
+		setUpper(s, param, true)
+	case "len":
+		setLower(s, param, false)
+		setUpper(s, param, false)
+	case "oneof":
+		s.Enum = nil
+		for _, v := range strings.Fields(param) {
+			s.Enum = append(s.Enum, enumValue(s, v))
+		}
+	case "unique":
+		if s.Type == "array" {
+			s.UniqueItems = true
+		}
+	}
+}
+
+func applyValid(s *Schema, name, args string) {
+	if s.Ref != "" {
+		return
+	}
+	if pattern, ok := patternRules[name]; ok {
+		s.Pattern = pattern
+		return
+	}
+	args = strings.TrimSpace(args)
+	switch name {
+	case "Min", "MinSize":
+		setLower(s, args, false)
+	case "Max", "MaxSize":
+		setUpper(s, args, false)
+	case "Length":
+		setLower(s, args, false)
+		setUpper(s, args, false)
+	case "Range":
+		if lo, hi, ok := strings.Cut(args, ","); ok {
+			setLower(s, strings.TrimSpace(lo), false)
+			setUpper(s, strings.TrimSpace(hi), false)
+		}
+	case "Match":
+		if len(args) >= 2 && args[0] == '/' && args[len(args)-1] == '/' {
+			s.Pattern = args[1 : len(args)-1]
+		}
+	case "Email":
+		s.Format = "email"
+	}
+}
+
+// setLower 数字为最小值，字符串为最小长度，数组为最少元素个数
+func setLower(s *Schema, param string, exclusive bool) {
+	f, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return
+	}
+	switch s.Type {
+	case "integer", "number":
+		s.Minimum, s.ExclusiveMinimum = &f, exclusive
+	case "string":
+		n := lengthOf(f, exclusive, 1)
+		s.MinLength = &n
+	case "array":
+		n := lengthOf(f, exclusive, 1)
+		s.MinItems = &n
+	}
+}
+
+func setUpper(s *Schema, param string, exclusive bool) {
+	f, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return
+	}
+	switch s.Type {
+	case "integer", "number":
+		s.Maximum, s.ExclusiveMaximum = &f, exclusive
+	case "string":
+		n := lengthOf(f, exclusive, -1)
+		s.MaxLength = &n
+	case "array":
+		n := lengthOf(f, exclusive, -1)
+		s.MaxItems = &n
+	}
+}
+
+// lengthOf gt=3的最小长度为4，lt=3的最大长度为2
+func lengthOf(f float64, exclusive bool, step int) int {
+	n := int(f)
+	if exclusive {
+		n += step
+	}
+	return n
+}
+
+func enumValue(s *Schema, v string) interface{} {
+	switch s.Type {
+	case "integer":
+		if i, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return i
+		}
+	case "number":
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return f
+		}
+	}
+	return v
+}
@@ -0,0 +1,38 @@
+package openapi
+
+import (
+	"bytes"
+	"embed"
+	"html/template"
+	"io/fs"
+)
+
+// SwaggerUIAssets swagger-ui-dist静态文件的地址，为空时使用内嵌的SwaggerAssets；
+// 需要更新版本时可以改为CDN或自己部署的地址
+var SwaggerUIAssets = ""
+
+//go:embed swagger-ui
+var swaggerFiles embed.FS
+
+// SwaggerAssets 内嵌的swagger-ui-dist静态文件，版本见swagger-ui/NOTICE
+var SwaggerAssets, _ = fs.Sub(swaggerFiles, "swagger-ui")
+
+//go:embed swagger.html
+var swaggerHTML string
+
+var swaggerTemplate = template.Must(template.New("swagger").Parse(swaggerHTML))
+
+// SwaggerUI 返回展示specURL文档的Swagger UI页面，assetsURL为SwaggerAssets的访问地址，
+// 设置了SwaggerUIAssets时忽略
+func SwaggerUI(title, specURL, assetsURL string) ([]byte, error) {
+	if SwaggerUIAssets != "" {
+		assetsURL = SwaggerUIAssets
+	}
+	var buf bytes.Buffer
+	err := swaggerTemplate.Execute(&buf, struct {
+		Title   string
+		Assets  string
+		SpecURL string
+	}{title, assetsURL, specURL})
+	return buf.Bytes(), err
+}
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hudangwei/common/api/openapi"
+)
+
+func TestHandleOpenAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	HandleOpenAPI(r.Group("/v1"), "/docs", openapi.Info{Title: "test", Version: "1.0"})
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+	page := get("/v1/docs")
+	if page.Code != http.StatusOK || !strings.Contains(page.Body.String(), `src="/v1/docs/assets/swagger-ui-bundle.js"`) {
+		t.Fatalf("page: %d %s", page.Code, page.Body)
+	}
+	for _, asset := range []string{"swagger-ui-bundle.js", "swagger-ui.css"} {
+		if w := get("/v1/docs/assets/" + asset); w.Code != http.StatusOK || w.Body.Len() == 0 {
+			t.Errorf("%s: status = %d", asset, w.Code)
+		}
+	}
+	if w := get("/v1/docs/openapi.json"); w.Code != http.StatusOK {
+		t.Errorf("spec: status = %d", w.Code)
+	}
+
+	defer func(assets string) { openapi.SwaggerUIAssets = assets }(openapi.SwaggerUIAssets)
+	openapi.SwaggerUIAssets = "https://cdn.example.com/swagger-ui-dist@5"
+	if w := get("/v1/docs"); !strings.Contains(w.Body.String(), `src="https://cdn.example.com/swagger-ui-dist@5/swagger-ui-bundle.js"`) {
+		t.Errorf("override: %s", w.Body)
+	}
+}
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"errors"
+
+	"github.com/hudangwei/common/depends"
+	"github.com/hudangwei/common/mysql"
+	"github.com/hudangwei/common/redis"
+	"github.com/jmoiron/sqlx"
+)
+
+// StaticPermissions 配置文件中的角色权限，如
+//
+//	[permissions.roles]
+//	admin = ["*"]
+//	member = ["tag:team", "get /v1/reports/*"]
+type StaticPermissions struct {
+	Roles map[string][]string `toml:"roles"`
+}
+
+// LoadStaticPermissions 从配置文件的name节读取角色权限
+func LoadStaticPermissions(f depends.Configger, name string) (*StaticPermissions, error) {
+	conf, err := f.LoadConfig(&StaticPermissions{}, name)
+	if err != nil {
+		return nil, err
+	}
+	perms, _ := conf.(*StaticPermissions)
+	if perms == nil {
+		return nil, errors.New("permissions config is nil")
+	}
+	return perms, nil
+}
+
+func (s *StaticPermissions) Permissions(role string) ([]string, error) {
+	return s.Roles[role], nil
+}
+
+// MysqlPermissions 从表中读取角色权限，表中每行为一项权限，需要role和permission两列
+type MysqlPermissions struct {
+	DB    *sqlx.DB
+	Table string
+}
+
+func (s *MysqlPermissions) Permissions(role string) ([]string, error) {
+	var perms []string
+	err := mysql.GetAll(s.DB, &perms, s.Table, map[string]interface{}{"role": role}, []string{"permission"})
+	return perms, err
+}
+
+// RedisPermissions 从Redis的集合 KeyPrefix+role 读取角色权限；集合不存在时从Next读取并写入Redis，
+// 过期时间为TTL秒，Next一般为MysqlPermissions
+type RedisPermissions struct {
+	Redis     *redis.Redis
+	KeyPrefix string
+	Next      PermissionSource
+	TTL       int64
+}
+
+func (s *RedisPermissions) Permissions(role string) ([]string, error) {
+	key := s.KeyPrefix + role
+	perms, err := s.Redis.SMembers(key)
+	if err != nil || len(perms) > 0 || s.Next == nil {
+		return perms, err
+	}
+	perms, err = s.Next.Permissions(role)
+	if err != nil || len(perms) == 0 {
+		return perms, err
+	}
+	members := make([]interface{}, len(perms))
+	for i, p := range perms {
+		members[i] = p
+	}
+	if _, err := s.Redis.SAdd(key, members...); err != nil {
+		return perms, nil
+	}
+	if s.TTL > 0 {
+		s.Redis.Expire(key, s.TTL)
+	}
+	return perms, nil
+}
+
+// Invalidate 删除角色在Redis中的权限，修改权限后调用
+func (s *RedisPermissions) Invalidate(roles ...string) error {
+	keys := make([]string, len(roles))
+	for i, role := range roles {
+		keys[i] = s.KeyPrefix + role
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	_, err := s.Redis.Del(keys...)
+	return err
+}
@@ -0,0 +1,263 @@
+// Package binding 把http请求中的数据按auto_read标签填充到结构体，macaron的Parse中间件和parse.Parse共用
+//
+//	type Input struct {
+//		Id    int64  `auto_read:"id,path"`        // 路由参数
+//		Token string `auto_read:"X-Token,header"` // 请求头
+//		Page  int    `auto_read:"page"`           // query/form参数
+//		Raw   []byte `auto_read:"@body"`          // 原始请求体
+//	}
+//
+// multipart请求体流式读取，不能同时绑定@body：输入结构体同时有@body和上传文件字段时Bind返回错误，
+// multipart请求绑定到有@body字段的结构体时返回400。上传的文件绑定到*UploadedFile字段，
+// 原来以 _字段名 为键保存文件名的用法已移除，文件名改为读取UploadedFile.Filename
+//
+// 支持字符串、布尔、整数、浮点数、time.Time/time.Duration(layout标签指定格式)、
+// 实现了encoding.TextUnmarshaler的类型及其指针；切片从重复参数或逗号分隔的值读取，
+// map[string]T从 name[key]=v 形式的参数读取
+package binding
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+
+	"github.com/hudangwei/common/binding/codec"
+)
+
+// PathParamsFunc 按名字读取路由参数，gin使用ctx.Param，chi使用chi.URLParam
+type PathParamsFunc func(name string) string
+
+// DefaultCodecs 默认支持的请求体格式
+func DefaultCodecs() []codec.Interface {
+	return []codec.Interface{
+		&codec.Json{},
+		&codec.MultipartForm{},
+		&codec.Form{},
+		&codec.Xml{},
+		&codec.Protobuf{},
+		&codec.Msgpack{},
+		&codec.Empty{},
+	}
+}
+
+// ErrMultipartBody multipart请求绑定到有@body字段的结构体
+var ErrMultipartBody = errors.New("@body is not supported for multipart/form-data requests")
+
+type Binder struct {
+	Codecs []codec.Interface // 按Content-Type的媒体类型选择，都不匹配时按json解析
+	Options
+}
+
+func New(codecs ...codec.Interface) *Binder {
+	return &Binder{Codecs: codecs}
+}
+
+// Bind 填充ptr指向的结构体，不做校验；pathParams为空时忽略path来源的字段
+// 请求体或参数格式错误时返回Errors，ptr实现OptionsProvider时覆盖b.Options
+func (b *Binder) Bind(ptr interface{}, req *http.Request, pathParams PathParamsFunc) error {
+	if ptr == nil {
+		return nil
+	}
+	isReadFromBody := false
+	switch req.Method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+		isReadFromBody = true
+	}
+	if t := reflect.TypeOf(ptr).Elem(); t.Kind() == reflect.Struct {
+		if p := planOf(t); p.needBody && p.hasFiles {
+			return fmt.Errorf("binding: %s has both @body and file fields, multipart bodies cannot be read as @body", t)
+		}
+	}
+	opts := b.Options
+	if p, ok := ptr.(OptionsProvider); ok {
+		opts = opts.merge(p.BindOptions())
+	}
+	if isReadFromBody && req.ContentLength != 0 {
+		if err := prepareBody(req, opts); err != nil {
+			return bodyError(err)
+		}
+	}
+	body, err := b.injectFieldFromBody(ptr, isReadFromBody, req, opts)
+	if err != nil {
+		return bodyError(err)
+	}
+	// 没有绑定到字段的上传文件立即删除
+	defer removeUnbound(body.files, ptr)
+	return injectFields(ptr, isReadFromBody, req, pathParams, body)
+}
+
+func bodyError(err error) error {
+	switch {
+	case errors.Is(err, ErrBodyTooLarge):
+		return Errors{{Field: "@body", Rule: RuleBodySize, Message: ErrBodyTooLarge.Error()}}
+	case errors.Is(err, ErrUnsupportedEncoding):
+		return Errors{{Field: "@body", Rule: RuleBodyEncoding, Message: err.Error()}}
+	case errors.Is(err, codec.ErrTooManyParts):
+		return Errors{{Field: "@body", Rule: RuleBodySize, Message: err.Error()}}
+	}
+	var pe *codec.PartError
+	if !errors.As(err, &pe) {
+		return Errors{{Field: "@body", Rule: RuleBody, Message: err.Error()}}
+	}
+	fe := &FieldError{Field: pe.Field, Rule: RuleBody, Message: pe.Err.Error()}
+	switch {
+	case errors.Is(pe.Err, codec.ErrFileTooLarge), errors.Is(pe.Err, codec.ErrUploadTooLarge), errors.Is(pe.Err, codec.ErrValueTooLarge):
+		fe.Rule = RuleFileSize
+	case errors.Is(pe.Err, codec.ErrFileType):
+		fe.Rule = RuleFileType
+	}
+	return Errors{fe}
+}
+
+func (b *Binder) codecOf(req *http.Request) codec.Interface {
+	if c := codec.Find(b.Codecs, req.Header.Get("Content-Type")); c != nil {
+		return c
+	}
+	return &codec.Json{}
+}
+
+// bodyData 请求体中按字段名读取的数据，由codec的类型决定
+type bodyData struct {
+	search codec.SearchMap // multipart的普通字段，只绑定到string和[]byte
+	values url.Values      // form-urlencoded，与query参数的规则相同
+	files  codec.Files
+}
+
+func (b *Binder) injectFieldFromBody(ptr interface{}, isReadFromBody bool, req *http.Request, opts Options) (body bodyData, err error) {
+	if !isReadFromBody || req.ContentLength == 0 {
+		return
+	}
+
+	coc := b.codecOf(req)
+	if _, ok := coc.(codec.FileSearch); ok && planOf(reflect.TypeOf(ptr).Elem()).needBody {
+		err = ErrMultipartBody
+		return
+	}
+	if dec, ok := coc.(codec.Decoder); ok {
+		// 没有@body字段时直接从请求体流式解码，不再缓存整个请求体
+		var r io.Reader = req.Body
+		if planOf(reflect.TypeOf(ptr).Elem()).needBody {
+			var bs []byte
+			if bs, err = codec.CopyBody(req); err != nil {
+				return
+			}
+			r = bytes.NewReader(bs)
+		}
+		if err = dec.Decode(r, ptr, codec.DecodeOptions{Strict: opts.Strict}); err != nil && err != io.EOF {
+			return
+		}
+		err = nil
+	} else if dir, ok := coc.(codec.Direct); ok {
+		if err = dir.Unmarshal(req, ptr); err != nil {
+			return
+		}
+	}
+
+	switch c := coc.(type) {
+	case codec.Values:
+		body.values, err = c.UnmarshalValues(req)
+	case codec.FileSearch:
+		body.search, body.files, err = c.UnmarshalFiles(req)
+	case codec.Search:
+		body.search, err = c.UnmarshalSearchMap(req)
+	}
+	return
+}
+
+func injectFields(ptr interface{}, isReadFromBody bool, req *http.Request, pathParams PathParamsFunc, body bodyData) error {
+	var errs Errors
+	input := reflect.ValueOf(ptr).Elem()
+	for _, f := range planOf(input.Type()).fields {
+		field := input.FieldByIndex(f.index)
+		if f.file != fileNone {
+			if isReadFromBody && f.src == "" {
+				setFiles(field, f.file, body.files[f.name])
+			}
+			continue
+		}
+		if f.src == "" && isReadFromBody && f.name == "@body" {
+			bs, err := codec.CopyBody(req)
+			if err != nil {
+				return Errors{{Field: f.name, Rule: RuleBody, Message: err.Error()}}
+			}
+			setBytes(field, bs)
+			continue
+		}
+		if f.src == "" && isReadFromBody && body.values == nil {
+			if fs := body.files[f.name]; len(fs) > 0 && field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.Uint8 {
+				// 兼容原来的用法，[]byte字段读取文件内容
+				bs, err := fs[0].Bytes()
+				if err != nil {
+					return Errors{{Field: f.name, Rule: RuleBody, Message: err.Error()}}
+				}
+				field.SetBytes(bs)
+				continue
+			}
+			if v, ok := body.search[f.name]; ok && f.set != nil {
+				if !setBytes(field, v) {
+					if err := f.set(field, []string{string(v)}); err != nil {
+						errs = append(errs, &FieldError{Field: f.name, Rule: RuleType, Message: err.Error()})
+					}
+				}
+			}
+			continue
+		}
+
+		var vals []string
+		switch f.src {
+		case "path":
+			if pathParams != nil {
+				vals = []string{pathParams(f.name)}
+			}
+		case "header":
+			vals = req.Header.Values(f.name)
+		default:
+			form := body.values
+			if !isReadFromBody || form == nil {
+				form = formOf(req)
+			}
+			if f.setMap != nil {
+				if err := f.setMap(field, form, f.name); err != nil {
+					errs = append(errs, &FieldError{Field: f.name, Rule: RuleType, Message: err.Error()})
+				}
+				continue
+			}
+			vals = form[f.name]
+		}
+		if f.set == nil {
+			continue
+		}
+		if err := f.set(field, vals); err != nil {
+			errs = append(errs, &FieldError{Field: f.name, Rule: RuleType, Message: err.Error()})
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// formOf 与req.FormValue相同，按需解析query和form参数
+func formOf(req *http.Request) url.Values {
+	if req.Form == nil {
+		_ = req.ParseMultipartForm(32 << 20)
+	}
+	return req.Form
+}
+
+// setBytes string和[]byte字段直接赋值，其他类型返回false
+func setBytes(field reflect.Value, bs []byte) bool {
+	switch {
+	case field.Kind() == reflect.String:
+		field.SetString(string(bs))
+	case field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.Uint8:
+		field.SetBytes(bs)
+	default:
+		return false
+	}
+	return true
+}
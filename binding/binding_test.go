@@ -0,0 +1,240 @@
+package binding
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type Page struct {
+	Page int  `auto_read:"page"`
+	Size *int `auto_read:"size"`
+}
+
+type input struct {
+	Page
+	Id      int64  `auto_read:"id,path"`
+	Token   string `auto_read:"X-Token,header"`
+	Enabled bool
+	Level   uint8 `auto_read:"level"`
+	hidden  string
+}
+
+func TestBindQuery(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/users/42?page=3&enabled=true&level=7", nil)
+	req.Header.Set("X-Token", "abc")
+	var in input
+	err := New().Bind(&in, req, func(name string) string {
+		if name == "id" {
+			return "42"
+		}
+		return ""
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := input{Page: Page{Page: 3}, Id: 42, Token: "abc", Enabled: true, Level: 7}
+	if !reflect.DeepEqual(in, want) {
+		t.Errorf("got %+v, want %+v", in, want)
+	}
+
+	for _, q := range []string{"page=x", "level=256", "enabled=maybe"} {
+		req := httptest.NewRequest(http.MethodGet, "/?"+q, nil)
+		if err := New().Bind(&input{}, req, nil); err == nil {
+			t.Errorf("%s: expected error", q)
+		}
+	}
+}
+
+func TestBindBody(t *testing.T) {
+	type body struct {
+		Name  string `json:"name"`
+		Token string `auto_read:"X-Token,header"`
+		Raw   []byte `auto_read:"@body"`
+	}
+	payload := `{"name":"bob"}`
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Token", "abc")
+	var in body
+	if err := New(DefaultCodecs()...).Bind(&in, req, nil); err != nil {
+		t.Fatal(err)
+	}
+	if in.Name != "bob" || in.Token != "abc" || string(in.Raw) != payload {
+		t.Errorf("got %+v", in)
+	}
+}
+
+func TestPlanCache(t *testing.T) {
+	typ := reflect.TypeOf(input{})
+	p := planOf(typ)
+	if planOf(typ) != p {
+		t.Error("plan not cached")
+	}
+	var names []string
+	for _, f := range p.fields {
+		names = append(names, f.name)
+	}
+	if want := []string{"page", "size", "id", "X-Token", "enabled", "level"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("got %v, want %v", names, want)
+	}
+}
+
+type level int
+
+func (l *level) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "low":
+		*l = 1
+	case "high":
+		*l = 2
+	default:
+		return fmt.Errorf("unknown level %s", text)
+	}
+	return nil
+}
+
+func TestBindTypes(t *testing.T) {
+	type types struct {
+		Ratio   float64           `auto_read:"ratio"`
+		Score   *float32          `auto_read:"score"`
+		Port    uint16            `auto_read:"port"`
+		Offset  int32             `auto_read:"offset"`
+		Since   time.Time         `auto_read:"since" layout:"2006-01-02"`
+		Until   *time.Time        `auto_read:"until"`
+		Created time.Time         `auto_read:"created" layout:"unix"`
+		Timeout time.Duration     `auto_read:"timeout"`
+		TTL     time.Duration     `auto_read:"ttl" layout:"s"`
+		Ids     []int64           `auto_read:"ids"`
+		Names   []string          `auto_read:"X-Name,header"`
+		Level   level             `auto_read:"level"`
+		Levels  []level           `auto_read:"levels"`
+		Filter  map[string]string `auto_read:"filter"`
+		Ranges  map[string][]int  `auto_read:"range"`
+	}
+	q := "ratio=0.5&score=1.5&port=8080&offset=-3&since=2024-05-01&until=2024-05-01T08:00:00Z&created=1700000000" +
+		"&timeout=1m30s&ttl=90&ids=1&ids=2,3&level=high&levels=low,high" +
+		"&filter[os]=linux&filter[arch]=amd64&range[port]=80,443"
+	req := httptest.NewRequest(http.MethodGet, "/?"+q, nil)
+	req.Header.Add("X-Name", "a,b")
+	req.Header.Add("X-Name", "c")
+	var in types
+	if err := New().Bind(&in, req, nil); err != nil {
+		t.Fatal(err)
+	}
+	score := float32(1.5)
+	until := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	want := types{
+		Ratio:   0.5,
+		Score:   &score,
+		Port:    8080,
+		Offset:  -3,
+		Since:   time.Date(2024, 5, 1, 0, 0, 0, 0, time.Local),
+		Until:   &until,
+		Created: time.Unix(1700000000, 0),
+		Timeout: 90 * time.Second,
+		TTL:     90 * time.Second,
+		Ids:     []int64{1, 2, 3},
+		Names:   []string{"a", "b", "c"},
+		Level:   2,
+		Levels:  []level{1, 2},
+		Filter:  map[string]string{"os": "linux", "arch": "amd64"},
+		Ranges:  map[string][]int{"port": {80, 443}},
+	}
+	if !reflect.DeepEqual(in, want) {
+		t.Errorf("got %+v, want %+v", in, want)
+	}
+
+	for _, q := range []string{"port=70000", "since=2024/05/01", "timeout=90", "ids=1,x", "level=mid", "range[a]=b"} {
+		req := httptest.NewRequest(http.MethodGet, "/?"+q, nil)
+		if err := New().Bind(&types{}, req, nil); err == nil {
+			t.Errorf("%s: expected error", q)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	type account struct {
+		Name  string `auto_read:"name" valid:"Required"`
+		Age   int    `auto_read:"age" valid:"Min(18)"`
+		Email string `valid:"Email"`
+		Port  uint16 `auto_read:"port"`
+		Ids   []int  `auto_read:"ids"`
+	}
+	req := httptest.NewRequest(http.MethodGet, "/?port=x&ids=1,y", nil)
+	err := New().Bind(&account{}, req, nil)
+	var errs Errors
+	if !errors.As(err, &errs) || len(errs) != 2 || errs[0].Field != "port" || errs[1].Field != "ids" || errs[1].Rule != RuleType {
+		t.Fatalf("got %v", err)
+	}
+
+	err = Validate(&account{Age: 3, Email: "bob"})
+	if !errors.As(err, &errs) {
+		t.Fatalf("got %v", err)
+	}
+	var got []string
+	for _, fe := range errs {
+		got = append(got, fe.Field+"."+fe.Rule)
+	}
+	if want := []string{"name.Required", "age.Min", "email.Email"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if errs[1].Param != 18 {
+		t.Errorf("got param %v", errs[1].Param)
+	}
+
+	errs.Translate(func(fe *FieldError, lang string) string {
+		if lang == "zh-CN" && fe.Rule == "Required" {
+			return "不能为空"
+		}
+		return ""
+	}, "zh-CN")
+	if errs[0].Message != "不能为空" || errs[1].Message == "" {
+		t.Errorf("got %v", errs)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader("{"))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept-Language", "zh-CN,zh;q=0.9")
+	if err := New().Bind(&account{}, req, nil); !errors.As(err, &errs) || errs[0].Rule != RuleBody {
+		t.Errorf("got %v", err)
+	}
+	if lang := AcceptLanguage(req); lang != "zh-CN" {
+		t.Errorf("got %s", lang)
+	}
+}
+
+func TestBindForm(t *testing.T) {
+	type form struct {
+		Name   string            `auto_read:"name"`
+		Age    int               `auto_read:"age"`
+		Ids    []int             `auto_read:"ids"`
+		Filter map[string]string `auto_read:"filter"`
+		Token  string            `auto_read:"X-Token,header"`
+		Raw    []byte            `auto_read:"@body"`
+	}
+	payload := "name=bob&age=30&ids=1&ids=2,3&filter%5Bos%5D=linux"
+	req := httptest.NewRequest(http.MethodPost, "/?name=query", strings.NewReader(payload))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	req.Header.Set("X-Token", "abc")
+	var in form
+	if err := New(DefaultCodecs()...).Bind(&in, req, nil); err != nil {
+		t.Fatal(err)
+	}
+	want := form{Name: "bob", Age: 30, Ids: []int{1, 2, 3}, Filter: map[string]string{"os": "linux"}, Token: "abc", Raw: []byte(payload)}
+	if !reflect.DeepEqual(in, want) {
+		t.Errorf("got %+v, want %+v", in, want)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader("age=x"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	var errs Errors
+	if err := New(DefaultCodecs()...).Bind(&form{}, req, nil); !errors.As(err, &errs) || errs[0].Field != "age" {
+		t.Errorf("got %v", err)
+	}
+}
@@ -0,0 +1,141 @@
+package binding
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+var (
+	// DefaultMaxBodySize Options.MaxBodySize为0时使用，0表示不限制；multipart请求体使用DefaultMaxMultipartBodySize
+	DefaultMaxBodySize int64 = 10 << 20
+	// DefaultMaxMultipartBodySize multipart请求体的默认上限，略大于codec.DefaultMaxTotalSize以容纳part头，0表示不限制
+	DefaultMaxMultipartBodySize int64 = 128 << 20
+	// DefaultMaxDecompressedSize Options.MaxDecompressedSize为0时使用，防止压缩炸弹
+	DefaultMaxDecompressedSize int64 = 32 << 20
+
+	ErrBodyTooLarge        = errors.New("request body too large")
+	ErrUnsupportedEncoding = errors.New("unsupported content encoding")
+)
+
+// Options 请求体的读取选项，大小为0时使用对应的Default值，小于0时不限制
+type Options struct {
+	MaxBodySize         int64 // 请求体大小上限(解压前)，超过时返回413
+	MaxDecompressedSize int64 // gzip/deflate请求体解压后的大小上限
+	Strict              bool  // 请求体中有输入结构体未定义的字段时报错，支持json和msgpack
+}
+
+// OptionsProvider 输入类型实现该接口时覆盖Binder的Options，用于单个接口的限制，如上传接口放宽大小
+//
+//	func (*UploadInput) BindOptions() binding.Options {
+//		return binding.Options{MaxBodySize: 100 << 20}
+//	}
+type OptionsProvider interface {
+	BindOptions() Options
+}
+
+// merge override中不为零值的项覆盖o
+func (o Options) merge(override Options) Options {
+	if override.MaxBodySize != 0 {
+		o.MaxBodySize = override.MaxBodySize
+	}
+	if override.MaxDecompressedSize != 0 {
+		o.MaxDecompressedSize = override.MaxDecompressedSize
+	}
+	if override.Strict {
+		o.Strict = true
+	}
+	return o
+}
+
+func limitOf(size, def int64) int64 {
+	if size == 0 {
+		size = def
+	}
+	if size < 0 {
+		return 0
+	}
+	return size
+}
+
+// prepareBody 限制请求体大小，按Content-Encoding解压，之后读取req.Body得到的是解压后的内容
+func prepareBody(req *http.Request, opts Options) error {
+	def := DefaultMaxBodySize
+	if strings.HasPrefix(strings.ToLower(req.Header.Get("Content-Type")), "multipart/") {
+		def = DefaultMaxMultipartBodySize
+	}
+	if max := limitOf(opts.MaxBodySize, def); max > 0 {
+		if req.ContentLength > max {
+			return ErrBodyTooLarge
+		}
+		req.Body = &limitedBody{ReadCloser: req.Body, n: max}
+	}
+
+	encoding := strings.ToLower(strings.TrimSpace(req.Header.Get("Content-Encoding")))
+	var r io.ReadCloser
+	var err error
+	switch encoding {
+	case "", "identity":
+		return nil
+	case "gzip", "x-gzip":
+		r, err = gzip.NewReader(req.Body)
+	case "deflate":
+		r, err = zlib.NewReader(req.Body)
+	default:
+		return fmt.Errorf("%w: %s", ErrUnsupportedEncoding, encoding)
+	}
+	if err != nil {
+		if errors.Is(err, ErrBodyTooLarge) {
+			return err
+		}
+		return fmt.Errorf("decompress body failed: %w", err)
+	}
+
+	body := &decompressedBody{ReadCloser: r, raw: req.Body}
+	if max := limitOf(opts.MaxDecompressedSize, DefaultMaxDecompressedSize); max > 0 {
+		req.Body = &limitedBody{ReadCloser: body, n: max}
+	} else {
+		req.Body = body
+	}
+	req.Header.Del("Content-Encoding")
+	req.ContentLength = -1
+	return nil
+}
+
+// limitedBody 读取超过n字节时返回ErrBodyTooLarge，而不是像io.LimitReader那样静默截断
+type limitedBody struct {
+	io.ReadCloser
+	n int64
+}
+
+func (l *limitedBody) Read(p []byte) (int, error) {
+	if l.n < 0 {
+		return 0, ErrBodyTooLarge
+	}
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+	n, err := l.ReadCloser.Read(p)
+	l.n -= int64(n)
+	if l.n < 0 {
+		return n, ErrBodyTooLarge
+	}
+	return n, err
+}
+
+type decompressedBody struct {
+	io.ReadCloser
+	raw io.ReadCloser
+}
+
+func (d *decompressedBody) Close() error {
+	err := d.ReadCloser.Close()
+	if rerr := d.raw.Close(); err == nil {
+		err = rerr
+	}
+	return err
+}
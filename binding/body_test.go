@@ -0,0 +1,107 @@
+package binding
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type strictInput struct {
+	Name string `json:"name"`
+}
+
+func (*strictInput) BindOptions() Options {
+	return Options{Strict: true, MaxBodySize: 64}
+}
+
+func compress(t *testing.T, encoding, s string) *bytes.Buffer {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	if encoding == "gzip" {
+		w = gzip.NewWriter(&buf)
+	} else {
+		w = zlib.NewWriter(&buf)
+	}
+	if _, err := w.Write([]byte(s)); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	return &buf
+}
+
+func jsonRequest(body io.Reader, encoding string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/", body)
+	req.Header.Set("Content-Type", "application/json")
+	if encoding != "" {
+		req.Header.Set("Content-Encoding", encoding)
+	}
+	return req
+}
+
+func TestBodyOptions(t *testing.T) {
+	type input struct {
+		Name string `json:"name"`
+	}
+	type rawInput struct {
+		Name string `json:"name"`
+		Raw  []byte `auto_read:"@body"`
+	}
+	payload := `{"name":"bob","extra":1}`
+
+	for _, encoding := range []string{"", "gzip", "deflate"} {
+		var body io.Reader = strings.NewReader(payload)
+		if encoding != "" {
+			body = compress(t, encoding, payload)
+		}
+		var in rawInput
+		if err := New(DefaultCodecs()...).Bind(&in, jsonRequest(body, encoding), nil); err != nil {
+			t.Fatalf("%s: %v", encoding, err)
+		}
+		if in.Name != "bob" || string(in.Raw) != payload {
+			t.Errorf("%s: got %+v", encoding, in)
+		}
+	}
+
+	// 流式读取时没有Content-Length
+	chunked := func(s string) io.Reader { return io.MultiReader(strings.NewReader(s)) }
+	bomb := compress(t, "gzip", `{"name":"`+strings.Repeat("a", 1<<20)+`"}`)
+
+	cases := []struct {
+		binder *Binder
+		ptr    interface{}
+		req    *http.Request
+		rule   string
+		status int
+	}{
+		{&Binder{Options: Options{MaxBodySize: 10}}, &input{}, jsonRequest(strings.NewReader(payload), ""), RuleBodySize, http.StatusRequestEntityTooLarge},
+		{&Binder{Options: Options{MaxBodySize: 10}}, &input{}, jsonRequest(chunked(payload), ""), RuleBodySize, http.StatusRequestEntityTooLarge},
+		{&Binder{Options: Options{MaxBodySize: 10}}, &rawInput{}, jsonRequest(chunked(payload), ""), RuleBodySize, http.StatusRequestEntityTooLarge},
+		{&Binder{Options: Options{MaxDecompressedSize: 1024}}, &input{}, jsonRequest(bomb, "gzip"), RuleBodySize, http.StatusRequestEntityTooLarge},
+		{&Binder{}, &input{}, jsonRequest(strings.NewReader(payload), "br"), RuleBodyEncoding, http.StatusUnsupportedMediaType},
+		{&Binder{}, &input{}, jsonRequest(strings.NewReader("not gzip"), "gzip"), RuleBody, http.StatusBadRequest},
+		{&Binder{Options: Options{Strict: true}}, &input{}, jsonRequest(strings.NewReader(payload), ""), RuleBody, http.StatusBadRequest},
+		{&Binder{}, &strictInput{}, jsonRequest(strings.NewReader(payload), ""), RuleBody, http.StatusBadRequest},
+		{&Binder{}, &strictInput{}, jsonRequest(strings.NewReader(`{"name":"`+strings.Repeat("a", 64)+`"}`), ""), RuleBodySize, http.StatusRequestEntityTooLarge},
+		{&Binder{}, &input{}, jsonRequest(chunked(`{"name":"`+strings.Repeat("a", int(DefaultMaxBodySize))+`"}`), ""), RuleBodySize, http.StatusRequestEntityTooLarge},
+		{&Binder{}, &input{}, jsonRequest(strings.NewReader(`{"name":"bob"} {"name":"eve"}`), ""), RuleBody, http.StatusBadRequest},
+		{&Binder{}, &input{}, jsonRequest(strings.NewReader(`{"name":"bob"}x`), ""), RuleBody, http.StatusBadRequest},
+	}
+	for i, c := range cases {
+		err := c.binder.Bind(c.ptr, c.req, nil)
+		var errs Errors
+		if !errors.As(err, &errs) || errs[0].Rule != c.rule || errs.StatusCode() != c.status {
+			t.Errorf("case %d: got %v, want %s", i, err, c.rule)
+		}
+	}
+
+	var in strictInput
+	if err := New().Bind(&in, jsonRequest(strings.NewReader(`{"name":"bob"}`+"\n"), ""), nil); err != nil || in.Name != "bob" {
+		t.Errorf("got %+v, %v", in, err)
+	}
+}
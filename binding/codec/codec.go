@@ -0,0 +1,38 @@
+package codec
+
+import (
+	"io"
+	"net/http"
+)
+
+type Interface interface {
+	ContentType() []string
+	Marshal(interface{}) ([]byte, error)
+}
+
+type Direct interface {
+	Interface
+	Unmarshal(*http.Request, interface{}) error
+}
+
+type SearchMap map[string][]byte
+type Search interface {
+	Interface
+	UnmarshalSearchMap(*http.Request) (SearchMap, error)
+}
+
+// Indenter 支持格式化输出的codec，用于?pretty=1调试
+type Indenter interface {
+	MarshalIndent(interface{}) ([]byte, error)
+}
+
+// DecodeOptions 解码选项
+type DecodeOptions struct {
+	Strict bool // 有结构体中未定义的字段时报错
+}
+
+// Decoder 可以直接从请求体流式解码的codec，不需要先把请求体全部读入内存
+type Decoder interface {
+	Interface
+	Decode(r io.Reader, ptr interface{}, opts DecodeOptions) error
+}
@@ -0,0 +1,144 @@
+package codec
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestFind(t *testing.T) {
+	codecs := []Interface{&Json{}, &MultipartForm{}, &Form{}, &Xml{}, &Protobuf{}, &Msgpack{}, &Empty{}}
+	cases := map[string]Interface{
+		"application/json":                  codecs[0],
+		"Application/JSON; charset=utf-8":   codecs[0],
+		"application/problem+json":          codecs[0],
+		"multipart/form-data; boundary=xx":  codecs[1],
+		"application/x-www-form-urlencoded": codecs[2],
+		"text/xml; charset=utf-8":           codecs[3],
+		"application/atom+xml":              codecs[3],
+		"application/x-protobuf":            codecs[4],
+		"application/msgpack":               codecs[5],
+		"text/plain":                        codecs[6],
+		"application/jsonp":                 nil,
+		"application/json-seq":              nil,
+		"":                                  nil,
+	}
+	for ct, want := range cases {
+		if got := Find(codecs, ct); got != want {
+			t.Errorf("%q: got %T, want %T", ct, got, want)
+		}
+	}
+}
+
+type item struct {
+	Name  string   `json:"name" xml:"name"`
+	Count int      `json:"count,omitempty" xml:"count"`
+	Tags  []string `json:"tags" xml:"tag"`
+}
+
+func roundTrip(t *testing.T, c Direct, in, out interface{}) {
+	bs, err := c.Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(bs))
+	if err := c.Unmarshal(req, out); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCodecs(t *testing.T) {
+	in := item{Name: "a", Count: 2, Tags: []string{"x", "y"}}
+	for _, c := range []Direct{&Json{}, &Xml{}, &Msgpack{}} {
+		var out item
+		roundTrip(t, c, &in, &out)
+		if !reflect.DeepEqual(in, out) {
+			t.Errorf("%T: got %+v", c, out)
+		}
+	}
+
+	var msg wrapperspb.StringValue
+	roundTrip(t, &Protobuf{}, wrapperspb.String("hello"), &msg)
+	if msg.Value != "hello" {
+		t.Errorf("protobuf: got %q", msg.Value)
+	}
+	if _, err := (&Protobuf{}).Marshal(&in); err == nil {
+		t.Error("protobuf should reject non proto.Message")
+	}
+
+	bs, err := (&Form{}).Marshal(item{Name: "a b", Tags: []string{"x", "y"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "name=a+b&tags=x&tags=y"; string(bs) != want {
+		t.Errorf("form: got %s, want %s", bs, want)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(bs))
+	values, err := (&Form{}).UnmarshalValues(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (url.Values{"name": {"a b"}, "tags": {"x", "y"}}); !reflect.DeepEqual(values, want) {
+		t.Errorf("form: got %v", values)
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	codecs := []Interface{&Json{}, &Xml{}, &Msgpack{}, &Protobuf{}}
+	cases := map[string][]string{
+		"":                                      {"application/json", "application/xml", "application/msgpack", "application/x-protobuf"},
+		"application/xml":                       {"application/xml"},
+		"text/xml, application/json;q=0.5":      {"text/xml", "application/json"},
+		"application/*;q=0.5, application/xml":  {"application/xml", "application/json", "application/msgpack", "application/x-protobuf"},
+		"*/*;q=0.1, application/msgpack":        {"application/msgpack", "application/json", "application/xml", "application/x-protobuf"},
+		"*/*, application/json;q=0":             {"application/xml", "application/msgpack", "application/x-protobuf"},
+		"text/html":                             {},
+		"application/json;q=abc, text/xml;q=.8": {"text/xml"},
+	}
+	for accept, want := range cases {
+		got := []string{}
+		for _, o := range Negotiate(codecs, accept) {
+			got = append(got, o.ContentType)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%q: got %v, want %v", accept, got, want)
+		}
+	}
+}
+
+func TestAcceptable(t *testing.T) {
+	cases := map[string]bool{
+		"":                              true,
+		"text/plain":                    false,
+		"text/csv, application/pdf":     false,
+		"application/json":              true,
+		"application/json;q=0":          false,
+		"*/*":                           true,
+		"*/*;q=0":                       false,
+		"*/*;q=0, application/json":     true,
+		"text/plain, */*;q=0.1":         true,
+		"application/*;q=0.5, */*;q=0":  true,
+		"application/json;q=0, */*;q=1": false,
+		"text/plain, application/*;q=0": false,
+	}
+	for accept, want := range cases {
+		if got := Acceptable(accept, "application/json"); got != want {
+			t.Errorf("%q: got %v, want %v", accept, got, want)
+		}
+	}
+}
+
+func TestXmlMap(t *testing.T) {
+	bs, err := (&Xml{}).Marshal(map[string]interface{}{"result": "ok", "data": map[string]int{"b": 2, "a": 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "<response><data><a>1</a><b>2</b></data><result>ok</result></response>"; string(bs) != want {
+		t.Errorf("got %s, want %s", bs, want)
+	}
+}
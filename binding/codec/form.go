@@ -0,0 +1,102 @@
+package codec
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+)
+
+// Values 请求体为键值对的codec，按query参数的规则绑定，支持类型转换、切片和map
+type Values interface {
+	Interface
+	UnmarshalValues(*http.Request) (url.Values, error)
+}
+
+type Form struct {
+}
+
+func (j *Form) ContentType() []string {
+	return []string{"application/x-www-form-urlencoded"}
+}
+
+func (j *Form) UnmarshalValues(req *http.Request) (url.Values, error) {
+	bs, err := CopyBody(req)
+	if err != nil {
+		return nil, err
+	}
+	values, err := url.ParseQuery(string(bs))
+	if err != nil {
+		return nil, fmt.Errorf("parse form body failed: %s", err)
+	}
+	return values, nil
+}
+
+// Marshal 支持url.Values、map[string]T和结构体，结构体的键取json标签中的名字
+func (j *Form) Marshal(ptr interface{}) ([]byte, error) {
+	if values, ok := ptr.(url.Values); ok {
+		return []byte(values.Encode()), nil
+	}
+	v := reflect.ValueOf(ptr)
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil, nil
+		}
+		v = v.Elem()
+	}
+	values := url.Values{}
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil, errors.New("form marshal: map key must be string")
+		}
+		iter := v.MapRange()
+		for iter.Next() {
+			addFormValue(values, iter.Key().String(), iter.Value())
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			if sf.PkgPath != "" {
+				continue
+			}
+			name, opts, _ := strings.Cut(sf.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = sf.Name
+			}
+			if strings.Contains(opts, "omitempty") && v.Field(i).IsZero() {
+				continue
+			}
+			addFormValue(values, name, v.Field(i))
+		}
+	default:
+		return nil, fmt.Errorf("form marshal: unsupported type %s", v.Type())
+	}
+	return []byte(values.Encode()), nil
+}
+
+func addFormValue(values url.Values, key string, v reflect.Value) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	if (v.Kind() == reflect.Slice || v.Kind() == reflect.Array) && v.Type().Elem().Kind() != reflect.Uint8 {
+		for i := 0; i < v.Len(); i++ {
+			addFormValue(values, key, v.Index(i))
+		}
+		return
+	}
+	if v.Kind() == reflect.Slice {
+		values.Add(key, string(v.Bytes()))
+		return
+	}
+	values.Add(key, fmt.Sprint(v.Interface()))
+}
@@ -0,0 +1,51 @@
+package codec
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+)
+
+// ErrTrailingData 请求体中第一个JSON值之后还有其他数据
+var ErrTrailingData = errors.New("unexpected data after top-level value")
+
+type Json struct {
+}
+
+func (j *Json) ContentType() []string {
+	return []string{"application/json"}
+}
+
+func (j *Json) Unmarshal(req *http.Request, ptr interface{}) error {
+	bs, err := CopyBody(req)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(bs, ptr)
+}
+
+func (j *Json) Decode(r io.Reader, ptr interface{}, opts DecodeOptions) error {
+	dec := json.NewDecoder(r)
+	if opts.Strict {
+		dec.DisallowUnknownFields()
+	}
+	if err := dec.Decode(ptr); err != nil {
+		return err
+	}
+	// 与json.Unmarshal一致，只允许一个JSON值
+	var extra json.RawMessage
+	if err := dec.Decode(&extra); err != io.EOF {
+		return ErrTrailingData
+	}
+	return nil
+}
+
+func (j *Json) Marshal(ptr interface{}) ([]byte, error) {
+	return json.Marshal(ptr)
+}
+
+func (j *Json) MarshalIndent(ptr interface{}) ([]byte, error) {
+	return json.MarshalIndent(ptr, "", "  ")
+}
@@ -0,0 +1,153 @@
+package codec
+
+import (
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MediaType 解析Content-Type，返回小写的媒体类型，如 "application/json; charset=utf-8" 返回 application/json
+func MediaType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	return mediaType
+}
+
+// Match codec是否支持该媒体类型
+func Match(c Interface, mediaType string) bool {
+	for _, ct := range c.ContentType() {
+		if strings.EqualFold(ct, mediaType) {
+			return true
+		}
+	}
+	return false
+}
+
+// Find 按Content-Type选择codec，支持 application/problem+json 这类带结构后缀的类型，找不到时返回nil
+func Find(codecs []Interface, contentType string) Interface {
+	mediaType := MediaType(contentType)
+	if mediaType == "" {
+		return nil
+	}
+	for _, c := range codecs {
+		if Match(c, mediaType) {
+			return c
+		}
+	}
+	if i := strings.LastIndexByte(mediaType, '+'); i >= 0 {
+		suffix := "application/" + mediaType[i+1:]
+		for _, c := range codecs {
+			if Match(c, suffix) {
+				return c
+			}
+		}
+	}
+	return nil
+}
+
+// Offer 协商出的响应格式
+type Offer struct {
+	Codec       Interface
+	ContentType string
+}
+
+// Negotiate 按Accept请求头对codecs排序，返回可接受的格式，优先级高的在前；
+// 每个codec取最具体的匹配范围的q值，q=0表示不接受；Accept为空时按codecs原有顺序
+func Negotiate(codecs []Interface, accept string) []Offer {
+	ranges := parseAccept(accept)
+	type scored struct {
+		Offer
+		q           float64
+		specificity int
+		index       int
+	}
+	var offers []scored
+	for i, c := range codecs {
+		best := scored{index: i, specificity: -1}
+		for _, ct := range c.ContentType() {
+			ct = strings.ToLower(ct)
+			if len(ranges) == 0 {
+				best = scored{Offer: Offer{c, ct}, q: 1, index: i}
+				break
+			}
+			for _, r := range ranges {
+				spec := r.match(ct)
+				if spec > best.specificity {
+					best = scored{Offer: Offer{c, ct}, q: r.q, specificity: spec, index: i}
+				}
+			}
+		}
+		if best.Codec != nil && best.q > 0 {
+			offers = append(offers, best)
+		}
+	}
+	sort.SliceStable(offers, func(i, j int) bool {
+		if offers[i].q != offers[j].q {
+			return offers[i].q > offers[j].q
+		}
+		return offers[i].specificity > offers[j].specificity
+	})
+	result := make([]Offer, 0, len(offers))
+	for _, o := range offers {
+		result = append(result, o.Offer)
+	}
+	return result
+}
+
+// Acceptable Accept请求头是否接受该媒体类型：Accept为空，或最具体的匹配范围(包括*/*、type/*)q>0
+func Acceptable(accept, mediaType string) bool {
+	ranges := parseAccept(accept)
+	if len(ranges) == 0 {
+		return true
+	}
+	mediaType = strings.ToLower(mediaType)
+	best, q := -1, 0.0
+	for _, r := range ranges {
+		if spec := r.match(mediaType); spec > best {
+			best, q = spec, r.q
+		}
+	}
+	return best >= 0 && q > 0
+}
+
+type acceptRange struct {
+	mediaType string
+	q         float64
+}
+
+// match 返回匹配的具体程度，*/*为0，type/*为1，完全匹配为2，不匹配为-1
+func (r acceptRange) match(mediaType string) int {
+	switch {
+	case r.mediaType == "*/*":
+		return 0
+	case strings.HasSuffix(r.mediaType, "/*") && strings.HasPrefix(mediaType, r.mediaType[:len(r.mediaType)-1]):
+		return 1
+	case r.mediaType == mediaType:
+		return 2
+	}
+	return -1
+}
+
+func parseAccept(accept string) []acceptRange {
+	var ranges []acceptRange
+	for _, part := range strings.Split(accept, ",") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		mediaType, params, err := mime.ParseMediaType(part)
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil || q < 0 || q > 1 {
+				continue
+			}
+		}
+		ranges = append(ranges, acceptRange{mediaType: mediaType, q: q})
+	}
+	return ranges
+}
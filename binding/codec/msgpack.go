@@ -0,0 +1,45 @@
+package codec
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Msgpack 字段名与json一致，使用json标签
+type Msgpack struct {
+}
+
+func (j *Msgpack) ContentType() []string {
+	return []string{"application/msgpack", "application/x-msgpack", "application/vnd.msgpack"}
+}
+
+func (j *Msgpack) Unmarshal(req *http.Request, ptr interface{}) error {
+	bs, err := CopyBody(req)
+	if err != nil {
+		return err
+	}
+
+	dec := msgpack.NewDecoder(bytes.NewReader(bs))
+	dec.SetCustomStructTag("json")
+	return dec.Decode(ptr)
+}
+
+func (j *Msgpack) Decode(r io.Reader, ptr interface{}, opts DecodeOptions) error {
+	dec := msgpack.NewDecoder(r)
+	dec.SetCustomStructTag("json")
+	dec.DisallowUnknownFields(opts.Strict)
+	return dec.Decode(ptr)
+}
+
+func (j *Msgpack) Marshal(ptr interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := msgpack.NewEncoder(&buf)
+	enc.SetCustomStructTag("json")
+	if err := enc.Encode(ptr); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,203 @@
+package codec
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// DefaultMultipartMemory 单个文件在内存中保存的上限，超过时写入临时文件
+const DefaultMultipartMemory = 10 << 20
+
+// MultipartForm各项限制的默认值
+const (
+	DefaultMaxFileSize  = 32 << 20  // 单个文件
+	DefaultMaxTotalSize = 100 << 20 // 所有文件和普通字段
+	DefaultMaxParts     = 1000      // part数量
+)
+
+// FileSearch 支持上传文件的codec，流式读取请求体
+type FileSearch interface {
+	Interface
+	UnmarshalFiles(*http.Request) (SearchMap, Files, error)
+}
+
+// MultipartForm 各项限制为0时使用默认值，MaxFileSize、MaxTotalSize和MaxParts小于0时不限制
+type MultipartForm struct {
+	MaxMemory    int64    // 单个文件或字段在内存中保存的上限，默认DefaultMultipartMemory，普通字段超过时报错
+	MaxFileSize  int64    // 单个文件大小上限，默认DefaultMaxFileSize
+	MaxTotalSize int64    // 所有文件和普通字段的总大小上限，默认DefaultMaxTotalSize
+	MaxParts     int      // part数量上限，默认DefaultMaxParts
+	AllowedTypes []string // 允许上传的Content-Type，支持image/*形式，为空时不限制
+	TempDir      string   // 临时文件目录，默认os.TempDir()
+}
+
+func (j *MultipartForm) ContentType() []string {
+	return []string{"multipart/form-data"}
+}
+
+// UnmarshalSearchMap 只返回普通字段，上传的文件被丢弃，需要文件时使用UnmarshalFiles
+func (j *MultipartForm) UnmarshalSearchMap(req *http.Request) (SearchMap, error) {
+	values, files, err := j.UnmarshalFiles(req)
+	files.RemoveAll()
+	return values, err
+}
+
+// UnmarshalFiles 逐个读取part，普通字段放入SearchMap，文件较大时写入临时文件，
+// 文件名在UploadedFile.Filename中，不再以 _字段名 为键放入SearchMap；
+// 出错时已保存的临时文件会被删除，成功时由调用方负责调用Files.RemoveAll
+func (j *MultipartForm) UnmarshalFiles(req *http.Request) (SearchMap, Files, error) {
+	reader, err := req.MultipartReader()
+	if err != nil {
+		return nil, nil, fmt.Errorf("read form-data input from body failed: %w", err)
+	}
+
+	maxMemory := j.MaxMemory
+	if maxMemory <= 0 {
+		maxMemory = DefaultMultipartMemory
+	}
+	maxTotal := limitOf(j.MaxTotalSize, DefaultMaxTotalSize)
+	maxParts := int64(limitOf(int64(j.MaxParts), DefaultMaxParts))
+	values := SearchMap{}
+	files := Files{}
+	var total, parts int64
+	for {
+		p, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			files.RemoveAll()
+			return nil, nil, fmt.Errorf("read next part from body failed: %w", err)
+		}
+		if parts++; parts > maxParts {
+			files.RemoveAll()
+			return nil, nil, fmt.Errorf("%w: more than %d", ErrTooManyParts, maxParts)
+		}
+		name := p.FormName()
+		if name == "" {
+			continue
+		}
+
+		if p.FileName() == "" {
+			limit, tooLarge := maxMemory, ErrValueTooLarge
+			if maxTotal-total < limit {
+				limit, tooLarge = maxTotal-total, ErrUploadTooLarge
+			}
+			bs, err := io.ReadAll(io.LimitReader(p, limit+1))
+			if err != nil {
+				files.RemoveAll()
+				return nil, nil, &PartError{Field: name, Err: err}
+			}
+			if int64(len(bs)) > limit {
+				files.RemoveAll()
+				return nil, nil, &PartError{Field: name, Err: tooLarge}
+			}
+			total += int64(len(bs))
+			if _, ok := values[name]; !ok {
+				values[name] = bs
+			}
+			continue
+		}
+
+		contentType := p.Header.Get("Content-Type")
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		if !j.allowed(contentType) {
+			files.RemoveAll()
+			return nil, nil, &PartError{Field: name, Err: ErrFileType}
+		}
+		f := &UploadedFile{Field: name, Filename: p.FileName(), ContentType: contentType, Header: p.Header}
+		if err := j.save(f, p, maxMemory, maxTotal-total); err != nil {
+			files.RemoveAll()
+			return nil, nil, &PartError{Field: name, Err: err}
+		}
+		total += f.Size
+		files[name] = append(files[name], f)
+	}
+	return values, files, nil
+}
+
+// save 先读入内存，超过maxMemory后剩余部分写入临时文件，remaining为总大小上限剩余的部分
+func (j *MultipartForm) save(f *UploadedFile, r io.Reader, maxMemory, remaining int64) error {
+	limit, tooLarge := limitOf(j.MaxFileSize, DefaultMaxFileSize), ErrFileTooLarge
+	if remaining < limit {
+		limit, tooLarge = remaining, ErrUploadTooLarge
+	}
+	r = io.LimitReader(r, limit+1)
+
+	var buf bytes.Buffer
+	n, err := io.CopyN(&buf, r, maxMemory+1)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	if n <= maxMemory {
+		f.content = buf.Bytes()
+		f.Size = n
+		return checkSize(f.Size, limit, tooLarge)
+	}
+
+	tmp, err := os.CreateTemp(j.TempDir, "multipart-")
+	if err != nil {
+		return err
+	}
+	f.tmpfile = tmp.Name()
+	size, err := io.Copy(tmp, io.MultiReader(&buf, r))
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = checkSize(size, limit, tooLarge)
+	}
+	if err != nil {
+		f.Remove()
+		return err
+	}
+	f.Size = size
+	return nil
+}
+
+// limitOf 为0时使用默认值，小于0时不限制
+func limitOf(v, def int64) int64 {
+	switch {
+	case v == 0:
+		return def
+	case v < 0:
+		return math.MaxInt64 - 1
+	}
+	return v
+}
+
+func checkSize(size, limit int64, tooLarge error) error {
+	if size > limit {
+		return tooLarge
+	}
+	return nil
+}
+
+func (j *MultipartForm) allowed(contentType string) bool {
+	if len(j.AllowedTypes) == 0 {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, t := range j.AllowedTypes {
+		t = strings.ToLower(t)
+		if t == mediaType || (strings.HasSuffix(t, "/*") && strings.HasPrefix(mediaType, t[:len(t)-1])) {
+			return true
+		}
+	}
+	return false
+}
+
+func (j *MultipartForm) Marshal(ptr interface{}) ([]byte, error) {
+	panic("MultipartForm not support marshal")
+}
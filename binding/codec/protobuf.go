@@ -0,0 +1,43 @@
+package codec
+
+import (
+	"fmt"
+	"net/http"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Protobuf 输入和返回值需要是proto.Message
+type Protobuf struct {
+}
+
+func (j *Protobuf) ContentType() []string {
+	return []string{"application/x-protobuf", "application/protobuf"}
+}
+
+func (j *Protobuf) Unmarshal(req *http.Request, ptr interface{}) error {
+	msg, ok := ptr.(proto.Message)
+	if !ok {
+		return fmt.Errorf("protobuf unmarshal: %T is not a proto.Message", ptr)
+	}
+	bs, err := CopyBody(req)
+	if err != nil {
+		return err
+	}
+
+	return proto.Unmarshal(bs, msg)
+}
+
+// CanMarshal 只有proto.Message可以编码为protobuf，用于响应格式协商
+func (j *Protobuf) CanMarshal(v interface{}) bool {
+	_, ok := v.(proto.Message)
+	return ok
+}
+
+func (j *Protobuf) Marshal(ptr interface{}) ([]byte, error) {
+	msg, ok := ptr.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("protobuf marshal: %T is not a proto.Message", ptr)
+	}
+	return proto.Marshal(msg)
+}
@@ -0,0 +1,124 @@
+package codec
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/textproto"
+	"os"
+)
+
+var (
+	ErrFileTooLarge   = errors.New("file too large")
+	ErrUploadTooLarge = errors.New("total upload size too large")
+	ErrValueTooLarge  = errors.New("form value too large")
+	ErrFileType       = errors.New("file type not allowed")
+	ErrTooManyParts   = errors.New("too many multipart parts")
+)
+
+// PartError multipart中某个字段出错，Err为上面定义的错误之一或读取错误
+type PartError struct {
+	Field string
+	Err   error
+}
+
+func (e *PartError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Err)
+}
+
+func (e *PartError) Unwrap() error {
+	return e.Err
+}
+
+// UploadedFile 上传的文件，较小的文件保存在内存中，超过MultipartForm.MaxMemory时保存在临时文件中
+type UploadedFile struct {
+	Field       string
+	Filename    string
+	Size        int64
+	ContentType string
+	Header      textproto.MIMEHeader
+
+	content []byte
+	tmpfile string
+}
+
+// Files 按字段名分组的上传文件
+type Files map[string][]*UploadedFile
+
+// RemoveAll 删除所有临时文件
+func (fs Files) RemoveAll() {
+	for _, files := range fs {
+		for _, f := range files {
+			f.Remove()
+		}
+	}
+}
+
+type sectionReadCloser struct {
+	*io.SectionReader
+}
+
+func (rc sectionReadCloser) Close() error {
+	return nil
+}
+
+// Open 打开文件内容，使用后需要Close
+func (f *UploadedFile) Open() (multipart.File, error) {
+	if f.tmpfile != "" {
+		return os.Open(f.tmpfile)
+	}
+	return sectionReadCloser{io.NewSectionReader(bytes.NewReader(f.content), 0, int64(len(f.content)))}, nil
+}
+
+// Bytes 读取全部内容，大文件应使用Open流式读取
+func (f *UploadedFile) Bytes() ([]byte, error) {
+	if f.tmpfile == "" {
+		return f.content, nil
+	}
+	return os.ReadFile(f.tmpfile)
+}
+
+// SaveTo 保存到dst，临时文件直接移动，调用后原文件不可再读取
+func (f *UploadedFile) SaveTo(dst string) error {
+	if f.tmpfile == "" {
+		return os.WriteFile(dst, f.content, 0644)
+	}
+	if err := os.Rename(f.tmpfile, dst); err == nil {
+		f.tmpfile = ""
+		f.content = nil
+		return nil
+	}
+	// 跨文件系统时无法rename，退回到复制
+	src, err := os.Open(f.tmpfile)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, src); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return f.Remove()
+}
+
+// Remove 删除临时文件，内存中的文件无需删除
+func (f *UploadedFile) Remove() error {
+	if f.tmpfile == "" {
+		return nil
+	}
+	err := os.Remove(f.tmpfile)
+	if errors.Is(err, os.ErrNotExist) {
+		err = nil
+	}
+	f.tmpfile = ""
+	return err
+}
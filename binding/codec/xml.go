@@ -0,0 +1,77 @@
+package codec
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+)
+
+type Xml struct {
+}
+
+func (j *Xml) ContentType() []string {
+	return []string{"application/xml", "text/xml"}
+}
+
+func (j *Xml) Unmarshal(req *http.Request, ptr interface{}) error {
+	bs, err := CopyBody(req)
+	if err != nil {
+		return err
+	}
+
+	return xml.Unmarshal(bs, ptr)
+}
+
+// Marshal encoding/xml不支持map，map[string]T编码为<response>下以键为名的元素
+func (j *Xml) Marshal(ptr interface{}) ([]byte, error) {
+	return xml.Marshal(xmlValue(ptr))
+}
+
+func (j *Xml) MarshalIndent(ptr interface{}) ([]byte, error) {
+	return xml.MarshalIndent(xmlValue(ptr), "", "  ")
+}
+
+func xmlValue(ptr interface{}) interface{} {
+	if v := reflect.ValueOf(ptr); v.Kind() == reflect.Map {
+		return xmlMap{name: "response", v: v}
+	}
+	return ptr
+}
+
+type xmlMap struct {
+	name string
+	v    reflect.Value
+}
+
+func (m xmlMap) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if m.v.Type().Key().Kind() != reflect.String {
+		return fmt.Errorf("xml: unsupported map key type %s", m.v.Type().Key())
+	}
+	start.Name.Local = m.name
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	keys := make([]string, 0, m.v.Len())
+	for _, k := range m.v.MapKeys() {
+		keys = append(keys, k.String())
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		val := m.v.MapIndex(reflect.ValueOf(k).Convert(m.v.Type().Key()))
+		for val.Kind() == reflect.Interface && !val.IsNil() {
+			val = val.Elem()
+		}
+		if val.Kind() == reflect.Map {
+			if err := e.Encode(xmlMap{name: k, v: val}); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := e.EncodeElement(val.Interface(), xml.StartElement{Name: xml.Name{Local: k}}); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
@@ -0,0 +1,81 @@
+package binding
+
+import (
+	"net/http"
+	"strings"
+)
+
+// FieldError.Rule中绑定阶段的取值，校验阶段为校验规则名，如Required、Min
+const (
+	RuleBody = "body" // 请求体解析失败
+	RuleType = "type" // 参数类型转换失败
+
+	RuleFileSize     = "file_size"     // 上传文件或字段超过大小限制
+	RuleFileType     = "file_type"     // 上传文件类型不允许
+	RuleBodySize     = "body_size"     // 请求体超过大小限制
+	RuleBodyEncoding = "body_encoding" // 不支持的Content-Encoding
+)
+
+// FieldError 单个字段绑定或校验失败
+type FieldError struct {
+	Field   string      `json:"field"` // 参数名，即auto_read中的名字
+	Rule    string      `json:"rule"`
+	Message string      `json:"message"`
+	Param   interface{} `json:"param,omitempty"` // 规则参数，如Min的下限，可用于翻译消息
+}
+
+func (e *FieldError) Error() string {
+	if e.Field == "" {
+		return e.Message
+	}
+	return e.Field + ": " + e.Message
+}
+
+// Errors 绑定或校验失败的所有字段，Bind和Validate返回的客户端错误都是该类型
+type Errors []*FieldError
+
+func (e Errors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, fe := range e {
+		msgs = append(msgs, fe.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// StatusCode 错误对应的http状态码，请求体过大为413，不支持的压缩格式为415，其他为400
+func (e Errors) StatusCode() int {
+	for _, fe := range e {
+		switch fe.Rule {
+		case RuleBodySize, RuleFileSize:
+			return http.StatusRequestEntityTooLarge
+		case RuleBodyEncoding:
+			return http.StatusUnsupportedMediaType
+		}
+	}
+	return http.StatusBadRequest
+}
+
+// Translator 翻译错误消息，lang为请求的首选语言，如zh-CN，返回空字符串时保留原消息
+type Translator func(fe *FieldError, lang string) string
+
+// Translate 原地翻译所有消息
+func (e Errors) Translate(t Translator, lang string) Errors {
+	if t == nil {
+		return e
+	}
+	for _, fe := range e {
+		if msg := t(fe, lang); msg != "" {
+			fe.Message = msg
+		}
+	}
+	return e
+}
+
+// AcceptLanguage 请求头Accept-Language中的首选语言
+func AcceptLanguage(req *http.Request) string {
+	lang := req.Header.Get("Accept-Language")
+	if i := strings.IndexAny(lang, ",;"); i >= 0 {
+		lang = lang[:i]
+	}
+	return strings.TrimSpace(lang)
+}
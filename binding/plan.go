@@ -0,0 +1,353 @@
+package binding
+
+import (
+	"encoding"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// plan 结构体的字段解析结果，按类型缓存，避免每次请求重复反射标签
+type plan struct {
+	fields      []fieldPlan
+	needBody    bool // 有@body字段，解码后还需要再次读取请求体
+	hasFiles    bool // 有上传文件字段，multipart请求体流式读取后不能再作为@body读取
+	legacyValid bool // 实现了beego的ValidFormer，见TagValidator
+}
+
+type fieldPlan struct {
+	index []int  // 嵌套结构体中的字段路径，用于FieldByIndex
+	file  int    // 上传文件字段: fileSingle、fileSlice
+	src   string // 来源: path、header，为空时读取query/form或请求体
+	name  string
+	set   func(field reflect.Value, vals []string) error
+	// map字段，从 name[key]=v 形式的query/form参数读取
+	setMap func(field reflect.Value, form url.Values, name string) error
+}
+
+var plans sync.Map // reflect.Type -> *plan
+
+func planOf(t reflect.Type) *plan {
+	if p, ok := plans.Load(t); ok {
+		return p.(*plan)
+	}
+	p := &plan{fields: buildFields(t, nil), legacyValid: hasLegacyValid(t)}
+	for _, f := range p.fields {
+		if f.src == "" && f.name == "@body" {
+			p.needBody = true
+		}
+		if f.file != fileNone {
+			p.hasFiles = true
+		}
+	}
+	actual, _ := plans.LoadOrStore(t, p)
+	return actual.(*plan)
+}
+
+// hasLegacyValid 是否实现了beego validation的ValidFormer，即 Valid(*validation.Validation)
+func hasLegacyValid(t reflect.Type) bool {
+	m, ok := reflect.PtrTo(t).MethodByName("Valid")
+	if !ok || m.Type.NumIn() != 2 {
+		return false
+	}
+	arg := m.Type.In(1)
+	return arg.Kind() == reflect.Ptr && arg.Elem().Kind() == reflect.Struct && arg.Elem().Name() == "Validation"
+}
+
+// CheckInput 检查输入结构体t能否绑定和校验，在注册路由时调用，使问题在启动时而不是请求时暴露
+func CheckInput(t reflect.Type) error {
+	if planOf(t).legacyValid {
+		return fmt.Errorf("binding: %s implements beego ValidFormer, which is no longer called; use TagValidator.RegisterStructRule instead", t)
+	}
+	return nil
+}
+
+// Field 输入结构体中参与绑定的字段，用于生成接口文档等
+type Field struct {
+	reflect.StructField        // Index为从根结构体开始的完整路径
+	Name                string // 参数名，@body表示整个请求体
+	Source              string // path、header，为空时读取query/form或请求体
+	File                bool   // 上传文件字段
+}
+
+// Fields 返回t中参与绑定的字段，嵌套结构体的字段已展开，t必须是结构体
+func Fields(t reflect.Type) []Field {
+	p := planOf(t)
+	fields := make([]Field, 0, len(p.fields))
+	for _, f := range p.fields {
+		sf := t.FieldByIndex(f.index)
+		sf.Index = f.index
+		fields = append(fields, Field{StructField: sf, Name: f.name, Source: f.src, File: f.file != fileNone})
+	}
+	return fields
+}
+
+func buildFields(t reflect.Type, prefix []int) []fieldPlan {
+	var fields []fieldPlan
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" && !sf.Anonymous {
+			continue
+		}
+		index := append(append([]int{}, prefix...), i)
+		if sf.Type.Kind() == reflect.Struct && !isScalar(sf.Type) {
+			fields = append(fields, buildFields(sf.Type, index)...)
+			continue
+		}
+		if sf.PkgPath != "" {
+			continue
+		}
+		src, name := getSourceWayAndName(sf)
+		f := fieldPlan{index: index, src: src, name: name}
+		layout := sf.Tag.Get("layout")
+		f.file = fileKindOf(sf.Type)
+		switch {
+		case f.file != fileNone:
+			// 上传文件只从multipart请求体读取
+		case sf.Type.Kind() == reflect.Map && sf.Type.Key().Kind() == reflect.String:
+			f.setMap = mapSetterOf(sf.Type, layout)
+		default:
+			f.set = setterOf(sf.Type, layout)
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+func getSourceWayAndName(field reflect.StructField) (src, name string) {
+	src, name = "", lowerFirst(field.Name)
+	tag := field.Tag.Get("auto_read")
+	if tag == "" {
+		return
+	}
+
+	tagArr := strings.Split(tag, ",")
+	name = strings.TrimSpace(tagArr[0])
+	if len(tagArr) > 1 {
+		src = strings.TrimSpace(tagArr[1])
+	}
+
+	return
+}
+
+func lowerFirst(str string) string {
+	for i, v := range str {
+		return string(unicode.ToLower(v)) + str[i+1:]
+	}
+	return ""
+}
+
+// setterOf 字符串转换为字段类型后赋值；没有值时指针字段保持不变，其他字段置为零值
+// 切片字段接收所有值，每个值再按逗号拆分，如 ?ids=1&ids=2,3
+func setterOf(t reflect.Type, layout string) func(reflect.Value, []string) error {
+	isPtr := t.Kind() == reflect.Ptr
+	base := t
+	if isPtr {
+		base = t.Elem()
+	}
+	if !isPtr && t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8 && !isScalar(t) {
+		return sliceSetterOf(t, layout)
+	}
+	parse := parserOf(base, layout)
+	return func(field reflect.Value, vals []string) error {
+		if len(vals) == 0 || vals[0] == "" {
+			if !isPtr {
+				field.Set(reflect.Zero(t))
+			}
+			return nil
+		}
+		if parse == nil {
+			return fmt.Errorf("unsupport type: %s", base)
+		}
+		v := reflect.New(base).Elem()
+		if err := parse(v, vals[0]); err != nil {
+			return err
+		}
+		if isPtr {
+			field.Set(v.Addr())
+		} else {
+			field.Set(v)
+		}
+		return nil
+	}
+}
+
+func sliceSetterOf(t reflect.Type, layout string) func(reflect.Value, []string) error {
+	elem := t.Elem()
+	parse := parserOf(elem, layout)
+	return func(field reflect.Value, vals []string) error {
+		if len(vals) == 0 {
+			field.Set(reflect.Zero(t))
+			return nil
+		}
+		if parse == nil {
+			return fmt.Errorf("unsupport type: %s", t)
+		}
+		s := reflect.MakeSlice(t, 0, len(vals))
+		for _, val := range vals {
+			for _, item := range strings.Split(val, ",") {
+				if item = strings.TrimSpace(item); item == "" {
+					continue
+				}
+				v := reflect.New(elem).Elem()
+				if err := parse(v, item); err != nil {
+					return err
+				}
+				s = reflect.Append(s, v)
+			}
+		}
+		if s.Len() == 0 {
+			s = reflect.Zero(t)
+		}
+		field.Set(s)
+		return nil
+	}
+}
+
+// mapSetterOf map[string]T字段从 name[key]=v 形式的参数读取
+func mapSetterOf(t reflect.Type, layout string) func(field reflect.Value, form url.Values, name string) error {
+	set := setterOf(t.Elem(), layout)
+	return func(field reflect.Value, form url.Values, name string) error {
+		m := reflect.MakeMap(t)
+		for k, vals := range form {
+			if !strings.HasPrefix(k, name+"[") || !strings.HasSuffix(k, "]") {
+				continue
+			}
+			key := reflect.New(t.Key()).Elem()
+			key.SetString(k[len(name)+1 : len(k)-1])
+			v := reflect.New(t.Elem()).Elem()
+			if err := set(v, vals); err != nil {
+				return err
+			}
+			m.SetMapIndex(key, v)
+		}
+		if m.Len() == 0 {
+			m = reflect.Zero(t)
+		}
+		field.Set(m)
+		return nil
+	}
+}
+
+var (
+	timeType            = reflect.TypeOf(time.Time{})
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// isScalar 作为单个值解析的类型，结构体中这些类型的字段不再展开
+func isScalar(t reflect.Type) bool {
+	return t == timeType || reflect.PtrTo(t).Implements(textUnmarshalerType)
+}
+
+// parserOf layout为字段的layout标签：
+// time.Time为时间格式，默认time.RFC3339，unix/unixmilli表示时间戳；
+// time.Duration为不带单位的数字的单位，如s、ms，默认只接受"1h30m"形式
+func parserOf(t reflect.Type, layout string) func(v reflect.Value, val string) error {
+	switch {
+	case t == timeType:
+		return timeParser(layout)
+	case t == durationType:
+		return func(v reflect.Value, val string) error {
+			if _, err := strconv.ParseFloat(val, 64); err == nil && layout != "" {
+				val += layout
+			}
+			d, err := time.ParseDuration(val)
+			if err != nil {
+				return fmt.Errorf("covert to duration failed: %s", err)
+			}
+			v.SetInt(int64(d))
+			return nil
+		}
+	case reflect.PtrTo(t).Implements(textUnmarshalerType):
+		return func(v reflect.Value, val string) error {
+			if err := v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(val)); err != nil {
+				return fmt.Errorf("covert to %s failed: %s", t, err)
+			}
+			return nil
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return func(v reflect.Value, val string) error {
+			v.SetString(val)
+			return nil
+		}
+	case reflect.Bool:
+		return func(v reflect.Value, val string) error {
+			b, err := strconv.ParseBool(val)
+			if err != nil {
+				return fmt.Errorf("covert to bool failed: %s", err)
+			}
+			v.SetBool(b)
+			return nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return func(v reflect.Value, val string) error {
+			i, err := strconv.ParseInt(val, 10, t.Bits())
+			if err != nil {
+				return fmt.Errorf("covert to %s failed: %s", t.Kind(), err)
+			}
+			v.SetInt(i)
+			return nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return func(v reflect.Value, val string) error {
+			u, err := strconv.ParseUint(val, 10, t.Bits())
+			if err != nil {
+				return fmt.Errorf("covert to %s failed: %s", t.Kind(), err)
+			}
+			v.SetUint(u)
+			return nil
+		}
+	case reflect.Float32, reflect.Float64:
+		return func(v reflect.Value, val string) error {
+			f, err := strconv.ParseFloat(val, t.Bits())
+			if err != nil {
+				return fmt.Errorf("covert to %s failed: %s", t.Kind(), err)
+			}
+			v.SetFloat(f)
+			return nil
+		}
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return func(v reflect.Value, val string) error {
+				v.SetBytes([]byte(val))
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+func timeParser(layout string) func(v reflect.Value, val string) error {
+	return func(v reflect.Value, val string) error {
+		var tm time.Time
+		var err error
+		switch layout {
+		case "unix", "unixmilli":
+			var n int64
+			n, err = strconv.ParseInt(val, 10, 64)
+			if layout == "unix" {
+				tm = time.Unix(n, 0)
+			} else {
+				tm = time.UnixMilli(n)
+			}
+		case "":
+			tm, err = time.Parse(time.RFC3339, val)
+		default:
+			tm, err = time.ParseInLocation(layout, val, time.Local)
+		}
+		if err != nil {
+			return fmt.Errorf("covert to time failed: %s", err)
+		}
+		v.Set(reflect.ValueOf(tm))
+		return nil
+	}
+}
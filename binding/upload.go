@@ -0,0 +1,90 @@
+package binding
+
+import (
+	"reflect"
+
+	"github.com/hudangwei/common/binding/codec"
+)
+
+// UploadedFile 上传文件字段的类型，使用*UploadedFile或[]*UploadedFile
+//
+//	type UploadInput struct {
+//		Avatar *binding.UploadedFile   `auto_read:"avatar"`
+//		Photos []*binding.UploadedFile `auto_read:"photos"`
+//	}
+//
+// 较大的文件保存在临时文件中，macaron的Parse中间件在请求结束后删除，
+// 直接使用parse.Parse时需要调用RemoveFiles
+type UploadedFile = codec.UploadedFile
+
+const (
+	fileNone = iota
+	fileSingle
+	fileSlice
+)
+
+var uploadedFileType = reflect.TypeOf((*UploadedFile)(nil))
+
+func fileKindOf(t reflect.Type) int {
+	switch {
+	case t == uploadedFileType:
+		return fileSingle
+	case t.Kind() == reflect.Slice && t.Elem() == uploadedFileType:
+		return fileSlice
+	}
+	return fileNone
+}
+
+func setFiles(field reflect.Value, kind int, files []*UploadedFile) {
+	if len(files) == 0 {
+		return
+	}
+	if kind == fileSingle {
+		field.Set(reflect.ValueOf(files[0]))
+		return
+	}
+	field.Set(reflect.ValueOf(files))
+}
+
+// boundFiles ptr中已绑定的上传文件
+func boundFiles(ptr interface{}) map[*UploadedFile]bool {
+	bound := map[*UploadedFile]bool{}
+	input := reflect.ValueOf(ptr).Elem()
+	for _, f := range planOf(input.Type()).fields {
+		switch f.file {
+		case fileSingle:
+			if file, _ := input.FieldByIndex(f.index).Interface().(*UploadedFile); file != nil {
+				bound[file] = true
+			}
+		case fileSlice:
+			for _, file := range input.FieldByIndex(f.index).Interface().([]*UploadedFile) {
+				bound[file] = true
+			}
+		}
+	}
+	return bound
+}
+
+func removeUnbound(files codec.Files, ptr interface{}) {
+	if len(files) == 0 {
+		return
+	}
+	bound := boundFiles(ptr)
+	for _, fs := range files {
+		for _, f := range fs {
+			if !bound[f] {
+				f.Remove()
+			}
+		}
+	}
+}
+
+// RemoveFiles 删除ptr中所有上传文件的临时文件
+func RemoveFiles(ptr interface{}) {
+	if ptr == nil {
+		return
+	}
+	for f := range boundFiles(ptr) {
+		f.Remove()
+	}
+}
@@ -0,0 +1,173 @@
+package binding
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/hudangwei/common/binding/codec"
+)
+
+type part struct {
+	name, filename, contentType, content string
+}
+
+func multipartRequest(t *testing.T, parts ...part) *http.Request {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for _, p := range parts {
+		h := textproto.MIMEHeader{}
+		if p.filename != "" {
+			h.Set("Content-Disposition", `form-data; name="`+p.name+`"; filename="`+p.filename+`"`)
+			h.Set("Content-Type", p.contentType)
+		} else {
+			h.Set("Content-Disposition", `form-data; name="`+p.name+`"`)
+		}
+		pw, err := w.CreatePart(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pw.Write([]byte(p.content))
+	}
+	w.Close()
+	req := httptest.NewRequest(http.MethodPost, "/", &body)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	return req
+}
+
+func TestBindUpload(t *testing.T) {
+	type upload struct {
+		Title  string          `auto_read:"title"`
+		Count  int             `auto_read:"count"`
+		Avatar *UploadedFile   `auto_read:"avatar"`
+		Photos []*UploadedFile `auto_read:"photos"`
+		Legacy []byte          `auto_read:"legacy"`
+	}
+	dir := t.TempDir()
+	mf := &codec.MultipartForm{MaxMemory: 8, TempDir: dir, AllowedTypes: []string{"image/*", "text/plain"}}
+	big := strings.Repeat("x", 100)
+	req := multipartRequest(t,
+		part{name: "title", content: "hello"},
+		part{name: "count", content: "3"},
+		part{name: "avatar", filename: "a.png", contentType: "image/png", content: "png"},
+		part{name: "photos", filename: "1.jpg", contentType: "image/jpeg", content: big},
+		part{name: "photos", filename: "2.jpg", contentType: "image/jpeg", content: "jpg"},
+		part{name: "legacy", filename: "l.txt", contentType: "text/plain", content: "legacy"},
+		part{name: "unbound", filename: "u.txt", contentType: "text/plain", content: big},
+	)
+	var in upload
+	if err := New(mf).Bind(&in, req, nil); err != nil {
+		t.Fatal(err)
+	}
+	if in.Title != "hello" || in.Count != 3 || string(in.Legacy) != "legacy" {
+		t.Errorf("got %+v", in)
+	}
+	if in.Avatar == nil || in.Avatar.Filename != "a.png" || in.Avatar.Size != 3 || in.Avatar.ContentType != "image/png" {
+		t.Fatalf("got avatar %+v", in.Avatar)
+	}
+	if len(in.Photos) != 2 || in.Photos[0].Size != 100 {
+		t.Fatalf("got photos %+v", in.Photos)
+	}
+	f, err := in.Photos[0].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs, _ := io.ReadAll(f)
+	f.Close()
+	if string(bs) != big {
+		t.Errorf("got %d bytes", len(bs))
+	}
+
+	// 大文件写入临时文件，未绑定的已删除
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("got %d temp files, want 1", len(entries))
+	}
+	RemoveFiles(&in)
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("got %d temp files after RemoveFiles", len(entries))
+	}
+
+	cases := []struct {
+		mf    *codec.MultipartForm
+		parts []part
+		rule  string
+	}{
+		{&codec.MultipartForm{AllowedTypes: []string{"image/*"}}, []part{{name: "avatar", filename: "a.exe", contentType: "application/x-msdownload", content: "x"}}, RuleFileType},
+		{&codec.MultipartForm{MaxFileSize: 10}, []part{{name: "avatar", filename: "a.png", contentType: "image/png", content: big}}, RuleFileSize},
+		{&codec.MultipartForm{MaxMemory: 8, MaxFileSize: 10, TempDir: dir}, []part{{name: "avatar", filename: "a.png", contentType: "image/png", content: big}}, RuleFileSize},
+		{&codec.MultipartForm{MaxTotalSize: 150, TempDir: dir}, []part{
+			{name: "photos", filename: "1.jpg", contentType: "image/jpeg", content: big},
+			{name: "photos", filename: "2.jpg", contentType: "image/jpeg", content: big},
+		}, RuleFileSize},
+		{&codec.MultipartForm{MaxMemory: 3}, []part{{name: "title", content: "hello"}}, RuleFileSize},
+		// 普通字段计入总大小
+		{&codec.MultipartForm{MaxTotalSize: 150, TempDir: dir}, []part{
+			{name: "photos", filename: "1.jpg", contentType: "image/jpeg", content: big},
+			{name: "title", content: big},
+		}, RuleFileSize},
+		{&codec.MultipartForm{MaxParts: 2}, []part{{name: "title", content: "a"}, {name: "count", content: "1"}, {name: "legacy", content: "b"}}, RuleBodySize},
+	}
+	for i, c := range cases {
+		err := New(c.mf).Bind(&upload{}, multipartRequest(t, c.parts...), nil)
+		var errs Errors
+		if !errors.As(err, &errs) || errs[0].Rule != c.rule {
+			t.Errorf("case %d: got %v, want %s", i, err, c.rule)
+		}
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("got %d temp files after failed uploads", len(entries))
+	}
+
+	// 默认限制part数量
+	parts := make([]part, codec.DefaultMaxParts+1)
+	for i := range parts {
+		parts[i] = part{name: "title", content: "a"}
+	}
+	var errs Errors
+	if err := New(&codec.MultipartForm{}).Bind(&upload{}, multipartRequest(t, parts...), nil); !errors.As(err, &errs) || errs[0].Rule != RuleBodySize {
+		t.Errorf("default max parts: got %v", err)
+	}
+}
+
+func TestBindMultipartBody(t *testing.T) {
+	type rawInput struct {
+		Title string `auto_read:"title"`
+		Raw   []byte `auto_read:"@body"`
+	}
+	type mixedInput struct {
+		Avatar *UploadedFile `auto_read:"avatar"`
+		Raw    []byte        `auto_read:"@body"`
+	}
+	b := New(DefaultCodecs()...)
+	err := b.Bind(&rawInput{}, multipartRequest(t, part{name: "title", content: "hello"}), nil)
+	var errs Errors
+	if !errors.As(err, &errs) || errs[0].Rule != RuleBody || errs.StatusCode() != http.StatusBadRequest {
+		t.Errorf("raw: got %v", err)
+	}
+	err = b.Bind(&mixedInput{}, multipartRequest(t, part{name: "avatar", filename: "a.png", contentType: "image/png", content: "png"}), nil)
+	if err == nil || errors.As(err, &errs) {
+		t.Errorf("mixed: got %v", err)
+	}
+
+	// multipart使用单独的默认上限
+	defer func(body, multipart int64) { DefaultMaxBodySize, DefaultMaxMultipartBodySize = body, multipart }(DefaultMaxBodySize, DefaultMaxMultipartBodySize)
+	DefaultMaxBodySize, DefaultMaxMultipartBodySize = 16, 1024
+	type titleInput struct {
+		Title string `auto_read:"title"`
+	}
+	if err := b.Bind(&titleInput{}, multipartRequest(t, part{name: "title", content: "hello"}), nil); err != nil {
+		t.Errorf("multipart larger than DefaultMaxBodySize: %v", err)
+	}
+	err = b.Bind(&titleInput{}, multipartRequest(t, part{name: "title", content: strings.Repeat("x", 2048)}), nil)
+	if !errors.As(err, &errs) || errs[0].Rule != RuleBodySize {
+		t.Errorf("multipart larger than DefaultMaxMultipartBodySize: got %v", err)
+	}
+}
@@ -0,0 +1,339 @@
+package binding
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// 兼容原beego validation的valid标签，规则以;分隔，如 valid:"Required;MaxSize(20);Match(/^\w+$/)"
+
+var (
+	alphaPattern        = regexp.MustCompile(`^[a-zA-Z]*$`)
+	numericPattern      = regexp.MustCompile(`^[0-9]*$`)
+	alphaNumericPattern = regexp.MustCompile(`^[a-zA-Z0-9]*$`)
+	alphaDashPattern    = regexp.MustCompile(`^[\w-]*$`)
+	emailPattern        = regexp.MustCompile(`^[\w!#$%&'*+/=?^_` + "`" + `{|}~-]+(?:\.[\w!#$%&'*+/=?^_` + "`" + `{|}~-]+)*@(?:[\w](?:[\w-]*[\w])?\.)+[a-zA-Z0-9](?:[\w-]*[\w])?$`)
+	mobilePattern       = regexp.MustCompile(`^((\+86)|(86))?1([356789][0-9]|4[579]|6[67]|7[0135678]|9[189])[0-9]{8}$`)
+	telPattern          = regexp.MustCompile(`^(0\d{2,3}(\-)?)?\d{7,8}$`)
+	zipCodePattern      = regexp.MustCompile(`^[1-9]\d{5}$`)
+)
+
+var legacyMessages = map[string]string{
+	"Required":     "Can not be empty",
+	"Min":          "Minimum is %v",
+	"Max":          "Maximum is %v",
+	"Range":        "Range is %v to %v",
+	"MinSize":      "Minimum size is %v",
+	"MaxSize":      "Maximum size is %v",
+	"Length":       "Required length is %v",
+	"Alpha":        "Must be valid alpha characters",
+	"Numeric":      "Must be valid numeric characters",
+	"AlphaNumeric": "Must be valid alpha or numeric characters",
+	"AlphaDash":    "Must be valid alpha or numeric or dash(-_) characters",
+	"Match":        "Must match %v",
+	"NoMatch":      "Must not match %v",
+	"Email":        "Must be a valid email address",
+	"IP":           "Must be a valid ip address",
+	"Base64":       "Must be valid base64 characters",
+	"Mobile":       "Must be valid mobile number",
+	"Tel":          "Must be valid telephone number",
+	"Phone":        "Must be valid telephone or mobile phone number",
+	"ZipCode":      "Must be valid zipcode",
+}
+
+// validLegacy 校验结构体及其嵌入结构体中带valid标签的字段
+func validLegacy(v reflect.Value) (Errors, error) {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil, nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil, nil
+	}
+
+	var errs Errors
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.Anonymous {
+			sub, err := validLegacy(v.Field(i))
+			if err != nil {
+				return nil, err
+			}
+			errs = append(errs, sub...)
+			continue
+		}
+		tag := sf.Tag.Get("valid")
+		if tag == "" || sf.PkgPath != "" {
+			continue
+		}
+		rules, err := legacyRulesOf(tag)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", sf.Name, err)
+		}
+		for _, r := range rules {
+			ok, params, err := checkLegacyRule(r.name, r.args, v.Field(i))
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", sf.Name, err)
+			}
+			if !ok {
+				fe := &FieldError{Field: fieldName(sf), Rule: r.name, Message: fmt.Sprintf(legacyMessages[r.name], params...)}
+				if len(params) == 1 {
+					fe.Param = params[0]
+				} else if len(params) > 1 {
+					fe.Param = params
+				}
+				errs = append(errs, fe)
+			}
+		}
+	}
+	return errs, nil
+}
+
+var (
+	legacyRules   sync.Map // tag -> []legacyRule
+	legacyRegexps sync.Map // expr -> *regexp.Regexp
+)
+
+func legacyRulesOf(tag string) ([]legacyRule, error) {
+	if rules, ok := legacyRules.Load(tag); ok {
+		return rules.([]legacyRule), nil
+	}
+	rules, err := parseLegacyRules(tag)
+	if err != nil {
+		return nil, err
+	}
+	legacyRules.Store(tag, rules)
+	return rules, nil
+}
+
+func legacyRegexp(expr string) (*regexp.Regexp, error) {
+	if re, ok := legacyRegexps.Load(expr); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	legacyRegexps.Store(expr, re)
+	return re, nil
+}
+
+type legacyRule struct {
+	name string
+	args string
+}
+
+// ValidRule valid标签中的一条规则，如 Range(1, 10) 的Name为Range，Args为"1, 10"
+type ValidRule struct {
+	Name string
+	Args string
+}
+
+// ValidRules 解析valid标签，用于生成接口文档等
+func ValidRules(tag string) ([]ValidRule, error) {
+	rules, err := legacyRulesOf(tag)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]ValidRule, len(rules))
+	for i, r := range rules {
+		out[i] = ValidRule{Name: r.name, Args: r.args}
+	}
+	return out, nil
+}
+
+// parseLegacyRules 参数中可能包含;，如Match(/a;b/)，因此以 ")" 加 ";" 或结尾作为参数的结束
+func parseLegacyRules(tag string) ([]legacyRule, error) {
+	var rules []legacyRule
+	for tag = strings.TrimSpace(tag); tag != ""; tag = strings.TrimSpace(tag) {
+		end := strings.IndexAny(tag, "(;")
+		if end < 0 {
+			rules = append(rules, legacyRule{name: tag})
+			break
+		}
+		if tag[end] == ';' {
+			if name := strings.TrimSpace(tag[:end]); name != "" {
+				rules = append(rules, legacyRule{name: name})
+			}
+			tag = tag[end+1:]
+			continue
+		}
+		name := strings.TrimSpace(tag[:end])
+		rest := tag[end+1:]
+		closing := -1
+		for i := 0; i < len(rest); i++ {
+			if rest[i] != ')' {
+				continue
+			}
+			if after := strings.TrimSpace(rest[i+1:]); after == "" || after[0] == ';' {
+				closing = i
+				break
+			}
+		}
+		if closing < 0 {
+			return nil, fmt.Errorf("invalid valid tag %q: missing )", tag)
+		}
+		rules = append(rules, legacyRule{name: name, args: rest[:closing]})
+		tag = strings.TrimPrefix(strings.TrimSpace(rest[closing+1:]), ";")
+	}
+	return rules, nil
+}
+
+func checkLegacyRule(name, args string, v reflect.Value) (bool, []interface{}, error) {
+	if _, ok := legacyMessages[name]; !ok {
+		return false, nil, fmt.Errorf("unknown valid rule %s", name)
+	}
+	if name == "Required" {
+		return !isEmpty(v), nil, nil
+	}
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			// 未传的可选参数不校验，需要时与Required一起使用
+			return true, nil, nil
+		}
+		v = v.Elem()
+	}
+
+	switch name {
+	case "Min", "Max", "MinSize", "MaxSize", "Length":
+		n, err := strconv.ParseFloat(strings.TrimSpace(args), 64)
+		if err != nil {
+			return false, nil, fmt.Errorf("invalid %s(%s)", name, args)
+		}
+		params := []interface{}{numberParam(n)}
+		switch name {
+		case "Min", "Max":
+			f, ok := numberOf(v)
+			if !ok {
+				return false, nil, fmt.Errorf("%s only supports numbers", name)
+			}
+			if name == "Min" {
+				return f >= n, params, nil
+			}
+			return f <= n, params, nil
+		}
+		size, ok := sizeOf(v)
+		if !ok {
+			return false, nil, fmt.Errorf("%s only supports strings, slices and maps", name)
+		}
+		switch name {
+		case "MinSize":
+			return float64(size) >= n, params, nil
+		case "MaxSize":
+			return float64(size) <= n, params, nil
+		}
+		return float64(size) == n, params, nil
+	case "Range":
+		parts := strings.Split(args, ",")
+		if len(parts) != 2 {
+			return false, nil, fmt.Errorf("invalid Range(%s)", args)
+		}
+		lo, err1 := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+		hi, err2 := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+		if err1 != nil || err2 != nil {
+			return false, nil, fmt.Errorf("invalid Range(%s)", args)
+		}
+		f, ok := numberOf(v)
+		if !ok {
+			return false, nil, fmt.Errorf("Range only supports numbers")
+		}
+		return f >= lo && f <= hi, []interface{}{numberParam(lo), numberParam(hi)}, nil
+	}
+
+	if v.Kind() != reflect.String {
+		return false, nil, fmt.Errorf("%s only supports strings", name)
+	}
+	s := v.String()
+	switch name {
+	case "Match", "NoMatch":
+		expr := strings.TrimSpace(args)
+		if len(expr) < 2 || expr[0] != '/' || expr[len(expr)-1] != '/' {
+			return false, nil, fmt.Errorf("invalid %s(%s): regexp must be enclosed in /", name, args)
+		}
+		re, err := legacyRegexp(expr[1 : len(expr)-1])
+		if err != nil {
+			return false, nil, fmt.Errorf("invalid %s(%s): %s", name, args, err)
+		}
+		params := []interface{}{re.String()}
+		if name == "Match" {
+			return re.MatchString(s), params, nil
+		}
+		return !re.MatchString(s), params, nil
+	case "Alpha":
+		return alphaPattern.MatchString(s), nil, nil
+	case "Numeric":
+		return numericPattern.MatchString(s), nil, nil
+	case "AlphaNumeric":
+		return alphaNumericPattern.MatchString(s), nil, nil
+	case "AlphaDash":
+		return alphaDashPattern.MatchString(s), nil, nil
+	case "Email":
+		return emailPattern.MatchString(s), nil, nil
+	case "IP":
+		return net.ParseIP(s) != nil, nil, nil
+	case "Base64":
+		_, err := base64.StdEncoding.DecodeString(s)
+		return err == nil, nil, nil
+	case "Mobile":
+		return mobilePattern.MatchString(s), nil, nil
+	case "Tel":
+		return telPattern.MatchString(s), nil, nil
+	case "Phone":
+		return mobilePattern.MatchString(s) || telPattern.MatchString(s), nil, nil
+	case "ZipCode":
+		return zipCodePattern.MatchString(s), nil, nil
+	}
+	return false, nil, fmt.Errorf("unknown valid rule %s", name)
+}
+
+// 整数参数按整数输出，如Min(18)的Param为18
+func numberParam(f float64) interface{} {
+	if f == float64(int(f)) {
+		return int(f)
+	}
+	return f
+}
+
+func isEmpty(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	case reflect.String:
+		return strings.TrimSpace(v.String()) == ""
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return v.Len() == 0
+	}
+	return v.IsZero()
+}
+
+func numberOf(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
+
+func sizeOf(v reflect.Value) (int, bool) {
+	switch v.Kind() {
+	case reflect.String:
+		return utf8.RuneCountInString(v.String()), true
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return v.Len(), true
+	}
+	return 0, false
+}
@@ -0,0 +1,187 @@
+package binding
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Validator 校验绑定后的结构体，参数不合法时应返回Errors
+type Validator interface {
+	Validate(ptr interface{}) error
+}
+
+// DefaultValidator Validate使用的校验器，可替换为自定义实现
+var DefaultValidator Validator = NewValidator()
+
+// Validate 使用DefaultValidator校验ptr，校验失败返回Errors，标签错误等返回普通error
+func Validate(ptr interface{}) error {
+	if DefaultValidator == nil {
+		return nil
+	}
+	return DefaultValidator.Validate(ptr)
+}
+
+// TagValidator 默认校验器，支持validate标签(go-playground/validator的规则)：
+//
+//	Name   string   `validate:"required,min=1"`
+//	Email  string   `validate:"omitempty,email"`
+//	Kind   string   `validate:"oneof=a b"`
+//	Repeat string   `validate:"eqfield=Password"`
+//	Items  []Item   `validate:"dive"`
+//
+// 同时兼容原有beego风格的valid标签，如 valid:"Required;Min(1)"。
+// beego的ValidFormer(Valid(*validation.Validation)方法)不再调用，注册路由时CheckInput对实现了该方法的输入类型报错，
+// 其中的校验需改为用RegisterStructRule注册：
+//
+//	DefaultValidator.(*TagValidator).RegisterStructRule(func(sl validator.StructLevel) {
+//		in := sl.Current().Interface().(Input)
+//		if in.Start > in.End {
+//			sl.ReportError(in.End, "End", "end", "gtefield", "start")
+//		}
+//	}, Input{})
+type TagValidator struct {
+	validate *validator.Validate
+	messages map[string]string
+}
+
+func NewValidator() *TagValidator {
+	v := &TagValidator{
+		validate: validator.New(),
+		messages: map[string]string{},
+	}
+	for rule, msg := range defaultMessages {
+		v.messages[rule] = msg
+	}
+	v.validate.RegisterTagNameFunc(func(sf reflect.StructField) string {
+		if sf.Anonymous {
+			return embeddedName
+		}
+		return fieldName(sf)
+	})
+	return v
+}
+
+// RegisterRule 注册自定义规则，message为默认消息，可包含一个%s表示规则参数
+func (v *TagValidator) RegisterRule(name string, fn validator.Func, message string) error {
+	if err := v.validate.RegisterValidation(name, fn); err != nil {
+		return err
+	}
+	v.messages[name] = message
+	return nil
+}
+
+// RegisterStructRule 注册结构体级别的规则，用于多个字段之间的复杂校验
+func (v *TagValidator) RegisterStructRule(fn validator.StructLevelFunc, types ...interface{}) {
+	v.validate.RegisterStructValidation(fn, types...)
+}
+
+func (v *TagValidator) Validate(ptr interface{}) error {
+	errs, err := validLegacy(reflect.ValueOf(ptr))
+	if err != nil {
+		return err
+	}
+
+	err = v.validate.Struct(ptr)
+	var verrs validator.ValidationErrors
+	if errors.As(err, &verrs) {
+		for _, fe := range verrs {
+			errs = append(errs, &FieldError{
+				Field:   namespaceOf(fe),
+				Rule:    fe.Tag(),
+				Message: v.message(fe),
+				Param:   paramOf(fe.Param()),
+			})
+		}
+	} else if err != nil {
+		return err
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (v *TagValidator) message(fe validator.FieldError) string {
+	msg, ok := v.messages[fe.Tag()]
+	if !ok {
+		return fmt.Sprintf("failed on the %s rule", fe.Tag())
+	}
+	if strings.Contains(msg, "%s") {
+		return fmt.Sprintf(msg, fe.Param())
+	}
+	return msg
+}
+
+func paramOf(param string) interface{} {
+	if param == "" {
+		return nil
+	}
+	return param
+}
+
+// 嵌入结构体在错误的字段路径中省略
+const embeddedName = "~"
+
+// namespaceOf 去掉根结构体名，如 Input.items[0].name 返回 items[0].name
+func namespaceOf(fe validator.FieldError) string {
+	parts := strings.Split(fe.Namespace(), ".")
+	names := make([]string, 0, len(parts))
+	for _, p := range parts[1:] {
+		if p != embeddedName {
+			names = append(names, p)
+		}
+	}
+	return strings.Join(names, ".")
+}
+
+// fieldName 错误信息中的字段名，依次取auto_read、json标签中的名字，否则为首字母小写的字段名
+func fieldName(sf reflect.StructField) string {
+	if tag := sf.Tag.Get("auto_read"); tag != "" {
+		if _, name := getSourceWayAndName(sf); name != "" && name != "@body" {
+			return name
+		}
+	}
+	if tag := sf.Tag.Get("json"); tag != "" {
+		if name := strings.Split(tag, ",")[0]; name != "" && name != "-" {
+			return name
+		}
+	}
+	return lowerFirst(sf.Name)
+}
+
+var defaultMessages = map[string]string{
+	"required":         "is required",
+	"required_if":      "is required",
+	"required_unless":  "is required",
+	"required_with":    "is required",
+	"required_without": "is required",
+	"min":              "must be at least %s",
+	"max":              "must be at most %s",
+	"len":              "must have length %s",
+	"gt":               "must be greater than %s",
+	"gte":              "must be at least %s",
+	"lt":               "must be less than %s",
+	"lte":              "must be at most %s",
+	"eq":               "must be equal to %s",
+	"ne":               "must not be equal to %s",
+	"oneof":            "must be one of [%s]",
+	"eqfield":          "must be equal to %s",
+	"nefield":          "must not be equal to %s",
+	"gtfield":          "must be greater than %s",
+	"gtefield":         "must be at least %s",
+	"ltfield":          "must be less than %s",
+	"ltefield":         "must be at most %s",
+	"email":            "must be a valid email address",
+	"url":              "must be a valid url",
+	"ip":               "must be a valid ip address",
+	"uuid":             "must be a valid uuid",
+	"alpha":            "must contain only letters",
+	"alphanum":         "must contain only letters and numbers",
+	"numeric":          "must be numeric",
+	"unique":           "must contain unique values",
+}
@@ -0,0 +1,104 @@
+package binding
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+)
+
+type address struct {
+	City string `json:"city" validate:"required"`
+}
+
+type signup struct {
+	Page
+	Name     string    `auto_read:"name" validate:"required,min=2"`
+	Email    string    `json:"email" validate:"omitempty,email"`
+	Kind     string    `validate:"oneof=a b"`
+	Password string    `validate:"required"`
+	Repeat   string    `validate:"eqfield=Password"`
+	Tags     []string  `validate:"max=2,dive,lowercase"`
+	Address  address   `json:"address"`
+	Extra    []address `json:"extra" validate:"dive"`
+	Code     string    `valid:"Required;Match(/^a;b$/)"`
+	Nick     string    `validate:"nick"`
+}
+
+func TestValidator(t *testing.T) {
+	v := NewValidator()
+	err := v.RegisterRule("nick", func(fl validator.FieldLevel) bool {
+		return !strings.Contains(fl.Field().String(), "admin")
+	}, "must not contain admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	in := signup{
+		Name:     "b",
+		Email:    "bob",
+		Kind:     "c",
+		Password: "x",
+		Repeat:   "y",
+		Tags:     []string{"ok", "Bad"},
+		Extra:    []address{{City: "sh"}, {}},
+		Code:     "a;c",
+		Nick:     "admin1",
+	}
+	err = v.Validate(&in)
+	var errs Errors
+	if !errors.As(err, &errs) {
+		t.Fatalf("got %v", err)
+	}
+	got := map[string]string{}
+	for _, fe := range errs {
+		got[fe.Field] = fe.Rule + ": " + fe.Message
+	}
+	want := map[string]string{
+		"code":          "Match: Must match ^a;b$",
+		"name":          "min: must be at least 2",
+		"email":         "email: must be a valid email address",
+		"kind":          "oneof: must be one of [a b]",
+		"repeat":        "eqfield: must be equal to Password",
+		"tags[1]":       "lowercase: failed on the lowercase rule",
+		"address.city":  "required: is required",
+		"extra[1].city": "required: is required",
+		"nick":          "nick: must not contain admin",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v\nwant %v", got, want)
+	}
+
+	ok := signup{Name: "bob", Kind: "a", Password: "x", Repeat: "x", Address: address{City: "sh"}, Code: "a;b"}
+	if err := v.Validate(&ok); err != nil {
+		t.Errorf("unexpected %v", err)
+	}
+
+	type bad struct {
+		Age int `valid:"Min(x)"`
+	}
+	if err := v.Validate(&bad{}); err == nil || errors.As(err, &errs) {
+		t.Errorf("invalid tag should return a plain error, got %v", err)
+	}
+}
+
+// Validation 与beego validation的类型同名，用于检测旧的ValidFormer
+type Validation struct{}
+
+type legacyInput struct {
+	Name string `validate:"required"`
+}
+
+func (in *legacyInput) Valid(v *Validation) {}
+
+func TestLegacyValidFormer(t *testing.T) {
+	err := CheckInput(reflect.TypeOf(legacyInput{}))
+	if err == nil || !strings.Contains(err.Error(), "RegisterStructRule") {
+		t.Fatalf("err = %v", err)
+	}
+	if err := CheckInput(reflect.TypeOf(address{})); err != nil {
+		t.Fatalf("err = %v", err)
+	}
+}
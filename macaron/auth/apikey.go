@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/hudangwei/common/macaron/user"
+)
+
+// APIKeyStore 按API Key查找用户，Key不存在时返回nil, nil
+type APIKeyStore interface {
+	User(key string) (*user.User, error)
+}
+
+// StaticAPIKeys 固定的API Key，键为Key的sha256十六进制值，避免在配置中保存明文，可用HashAPIKey生成
+type StaticAPIKeys map[string]*user.User
+
+// HashAPIKey 返回key的sha256十六进制值
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func (ks StaticAPIKeys) User(key string) (*user.User, error) {
+	hash := HashAPIKey(key)
+	for h, u := range ks {
+		if subtle.ConstantTimeCompare([]byte(h), []byte(hash)) == 1 {
+			copied := *u
+			return &copied, nil
+		}
+	}
+	return nil, nil
+}
+
+// APIKey 从Header(默认X-Api-Key)或Query读取API Key，用于服务间调用等
+type APIKey struct {
+	Store  APIKeyStore
+	Header string
+	Query  string
+}
+
+func (a *APIKey) Authenticate(req *http.Request) (*user.User, error) {
+	header := a.Header
+	if header == "" {
+		header = "X-Api-Key"
+	}
+	key := credential(req, header, "", "", a.Query)
+	if key == "" {
+		return nil, nil
+	}
+	u, err := a.Store.User(key)
+	if err != nil {
+		return nil, err
+	}
+	if u == nil {
+		return nil, ErrInvalidAPIKey
+	}
+	return u, nil
+}
@@ -0,0 +1,90 @@
+// Package auth 从请求中识别用户，支持JWT、Redis会话和API Key，识别出的用户放入请求的context，
+// macaron.Context.User由此填充
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hudangwei/common/logger"
+	"github.com/hudangwei/common/macaron"
+	"github.com/hudangwei/common/macaron/user"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrInvalidToken   = errors.New("invalid token")
+	ErrTokenExpired   = errors.New("token expired")
+	ErrUnknownKey     = errors.New("unknown signing key")
+	ErrInvalidSession = errors.New("invalid session")
+	ErrInvalidAPIKey  = errors.New("invalid api key")
+	// ErrInvalidClaims exp、nbf等声明的类型错误，errors.Is(err, ErrInvalidToken)也成立
+	ErrInvalidClaims = fmt.Errorf("%w: invalid claims", ErrInvalidToken)
+)
+
+// Authenticator 从请求中识别用户；请求中没有对应的凭证时返回nil, nil，凭证无效时返回错误
+type Authenticator interface {
+	Authenticate(req *http.Request) (*user.User, error)
+}
+
+// Chain 按顺序尝试，返回第一个识别出的用户，某个Authenticator返回错误时不再尝试后面的
+type Chain []Authenticator
+
+func (c Chain) Authenticate(req *http.Request) (*user.User, error) {
+	for _, a := range c {
+		u, err := a.Authenticate(req)
+		if err != nil || u != nil {
+			return u, err
+		}
+	}
+	return nil, nil
+}
+
+// ErrorHandler 凭证无效时的响应，可替换以自定义格式
+var ErrorHandler = func(ctx *gin.Context, err error) {
+	e := macaron.NewError("error", err.Error(), http.StatusUnauthorized)
+	ctx.AbortWithStatusJSON(e.Code, e)
+}
+
+// Middleware gin中间件，识别出的用户放入请求的context；没有凭证时按未登录继续，
+// 是否需要登录由api.Authorize等后续的鉴权决定；凭证无效时返回401
+func Middleware(authenticators ...Authenticator) gin.HandlerFunc {
+	chain := Chain(authenticators)
+	return func(ctx *gin.Context) {
+		u, err := chain.Authenticate(ctx.Request)
+		if err != nil {
+			logger.Warn("authenticate with error", zap.Error(err), zap.String("path", ctx.Request.URL.Path))
+			ErrorHandler(ctx, err)
+			return
+		}
+		if u != nil {
+			ctx.Request = ctx.Request.WithContext(user.NewContextWithUser(ctx.Request.Context(), u))
+		}
+	}
+}
+
+// credential 依次从header(可带scheme前缀，如Bearer)、cookie和query参数读取凭证
+func credential(req *http.Request, header, scheme, cookie, query string) string {
+	if header != "" {
+		if v := req.Header.Get(header); v != "" {
+			if scheme == "" {
+				return v
+			}
+			if len(v) > len(scheme) && strings.EqualFold(v[:len(scheme)], scheme) && v[len(scheme)] == ' ' {
+				return strings.TrimSpace(v[len(scheme)+1:])
+			}
+		}
+	}
+	if cookie != "" {
+		if c, err := req.Cookie(cookie); err == nil && c.Value != "" {
+			return c.Value
+		}
+	}
+	if query != "" {
+		return req.URL.Query().Get(query)
+	}
+	return ""
+}
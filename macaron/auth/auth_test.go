@@ -0,0 +1,199 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hudangwei/common/logger"
+	"github.com/hudangwei/common/macaron"
+	"github.com/hudangwei/common/macaron/user"
+	"github.com/hudangwei/common/redis"
+)
+
+var _ RedisClient = (*redis.Redis)(nil)
+
+// TestMain 日志写到临时目录，避免在包目录下生成logs
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "auth-logs")
+	if err != nil {
+		panic(err)
+	}
+	logger.Init(dir, "server.log", "debug")
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+type fakeRedis map[string][]byte
+
+func (r fakeRedis) Get(key string) (interface{}, error) {
+	if v, ok := r[key]; ok {
+		return v, nil
+	}
+	return nil, nil
+}
+
+func (r fakeRedis) SetEx(key string, value interface{}, timeout int64) error {
+	r[key] = value.([]byte)
+	return nil
+}
+
+func (r fakeRedis) Expire(key string, duration int64) (int64, error) {
+	return 1, nil
+}
+
+func (r fakeRedis) Del(keys ...string) (int64, error) {
+	for _, key := range keys {
+		delete(r, key)
+	}
+	return int64(len(keys)), nil
+}
+
+func writeJWKS(t *testing.T, path string, keys ...map[string]string) {
+	bs, _ := json.Marshal(map[string]interface{}{"keys": keys})
+	if err := os.WriteFile(path, bs, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestJWT(t *testing.T) {
+	enc := base64.RawURLEncoding
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	edPub, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	newPub, newKey, _ := ed25519.GenerateKey(rand.Reader)
+	secret := []byte("secret")
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	rsaJWK := map[string]string{"kty": "RSA", "kid": "rsa1", "n": enc.EncodeToString(rsaKey.N.Bytes()), "e": enc.EncodeToString(big.NewInt(int64(rsaKey.E)).Bytes())}
+	edJWK := map[string]string{"kty": "OKP", "kid": "ed1", "crv": "Ed25519", "x": enc.EncodeToString(edPub)}
+	writeJWKS(t, path, rsaJWK, edJWK)
+	jwks, err := NewJWKSFile(path, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := time.Now().Add(time.Hour).Unix()
+	claims := Claims{"sub": "42", "name": "bob", "roles": []string{"admin"}, "exp": exp, "iss": "me", "aud": []string{"api"}}
+	sign := func(claims Claims, alg, kid string, key interface{}) string {
+		token, err := Sign(claims, alg, kid, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	hs := &JWT{Keys: Keys{"": secret}, Issuer: "me", Audience: "api"}
+	rs := &JWT{Keys: jwks}
+	cases := []struct {
+		jwt   *JWT
+		token string
+		err   error
+	}{
+		{hs, sign(claims, HS256, "", secret), nil},
+		{hs, sign(claims, HS256, "", []byte("other")), ErrInvalidToken},
+		{hs, sign(Claims{"sub": "42", "exp": time.Now().Add(-time.Minute).Unix()}, HS256, "", secret), ErrTokenExpired},
+		{hs, sign(Claims{"sub": "42", "iss": "other"}, HS256, "", secret), ErrInvalidToken},
+		{rs, sign(claims, RS256, "rsa1", rsaKey), nil},
+		{rs, sign(claims, EdDSA, "ed1", edKey), nil},
+		{rs, sign(claims, EdDSA, "rsa1", edKey), ErrUnknownKey},
+		{rs, sign(claims, HS256, "ed1", []byte(edPub)), ErrUnknownKey},
+		{rs, "a.b", ErrInvalidToken},
+		{hs, sign(Claims{"sub": "42", "exp": "never"}, HS256, "", secret), ErrInvalidClaims},
+		{hs, sign(Claims{"sub": "42", "nbf": true}, HS256, "", secret), ErrInvalidClaims},
+		{hs, sign(Claims{"sub": "42", "exp": nil}, HS256, "", secret), ErrInvalidClaims},
+	}
+	for i, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+c.token)
+		u, err := c.jwt.Authenticate(req)
+		if !errors.Is(err, c.err) {
+			t.Errorf("case %d: err = %v, want %v", i, err, c.err)
+			continue
+		}
+		if err == nil && (u.Uid != 42 || u.UserId != "42" || u.UserName != "bob" || len(u.Roles) != 1) {
+			t.Errorf("case %d: user = %+v", i, u)
+		}
+	}
+
+	// 轮换：文件中加入新密钥后，未知的kid触发重新加载
+	token := sign(claims, EdDSA, "ed2", newKey)
+	if _, err := rs.Verify(token); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("err = %v", err)
+	}
+	writeJWKS(t, path, edJWK, map[string]string{"kty": "OKP", "kid": "ed2", "crv": "Ed25519", "x": enc.EncodeToString(newPub)})
+	jwks.checkedAt = time.Now().Add(-2 * time.Second)
+	if _, err := rs.Verify(token); err != nil {
+		t.Fatalf("err = %v", err)
+	}
+	if _, err := rs.Verify(sign(claims, RS256, "rsa1", rsaKey)); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("removed key: err = %v", err)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	secret := []byte("secret")
+	sessions := &Session{Redis: fakeRedis{}}
+	sid, err := sessions.Create(&user.User{Uid: 7, UserName: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := StaticAPIKeys{HashAPIKey("k1"): {UserId: "svc", Roles: []string{"service"}}}
+
+	m := macaron.New()
+	var got user.User
+	r := gin.New()
+	r.Use(Middleware(&JWT{Keys: Keys{"": secret}}, sessions, &APIKey{Store: keys}))
+	r.GET("/", m.Wraps(func(ctx *macaron.Context) { got = ctx.User }))
+
+	token, _ := Sign(Claims{"uid": 1, "sub": "u1"}, HS256, "", secret)
+	cases := []struct {
+		header, value string
+		status        int
+		want          user.User
+	}{
+		{"", "", http.StatusOK, user.User{}},
+		{"Authorization", "Bearer " + token, http.StatusOK, user.User{Uid: 1, UserId: "u1"}},
+		{"Authorization", "Bearer bad", http.StatusUnauthorized, user.User{}},
+		{"X-Session-Id", sid, http.StatusOK, user.User{Uid: 7, UserName: "alice", SessionId: sid}},
+		{"X-Session-Id", "missing", http.StatusUnauthorized, user.User{}},
+		{"X-Api-Key", "k1", http.StatusOK, user.User{UserId: "svc", Roles: []string{"service"}}},
+		{"X-Api-Key", "k2", http.StatusUnauthorized, user.User{}},
+	}
+	for _, c := range cases {
+		got = user.User{}
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if c.header != "" {
+			req.Header.Set(c.header, c.value)
+		}
+		r.ServeHTTP(w, req)
+		if w.Code != c.status {
+			t.Errorf("%s: status = %d, want %d", c.header, w.Code, c.status)
+		}
+		gotJSON, _ := json.Marshal(got)
+		wantJSON, _ := json.Marshal(c.want)
+		if string(gotJSON) != string(wantJSON) {
+			t.Errorf("%s: user = %s, want %s", c.header, gotJSON, wantJSON)
+		}
+	}
+
+	if err := sessions.Delete(sid); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sessions.Load(sid); !errors.Is(err, ErrInvalidSession) {
+		t.Errorf("err = %v", err)
+	}
+}
@@ -0,0 +1,165 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/hudangwei/common/logger"
+	"go.uber.org/zap"
+)
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	K   string `json:"k"`
+}
+
+type jwkKey struct {
+	alg string
+	key interface{}
+}
+
+// parseJWKS 解析JWKS，支持RSA、OKP(Ed25519)和oct(HMAC)类型的密钥，键为kid
+func parseJWKS(data []byte) (map[string]jwkKey, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]jwkKey, len(set.Keys))
+	for _, k := range set.Keys {
+		key, alg, err := k.parse()
+		if err != nil {
+			return nil, fmt.Errorf("jwk %q: %w", k.Kid, err)
+		}
+		if k.Alg != "" && k.Alg != alg {
+			return nil, fmt.Errorf("jwk %q: alg %s not match kty %s", k.Kid, k.Alg, k.Kty)
+		}
+		keys[k.Kid] = jwkKey{alg: alg, key: key}
+	}
+	return keys, nil
+}
+
+func (k *jwk) parse() (interface{}, string, error) {
+	dec := base64.RawURLEncoding
+	switch k.Kty {
+	case "RSA":
+		n, err1 := dec.DecodeString(k.N)
+		e, err2 := dec.DecodeString(k.E)
+		if err1 != nil || err2 != nil || len(n) == 0 || len(e) == 0 || len(e) > 4 {
+			return nil, "", fmt.Errorf("invalid rsa key")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, RS256, nil
+	case "OKP":
+		x, err := dec.DecodeString(k.X)
+		if err != nil || k.Crv != "Ed25519" || len(x) != ed25519.PublicKeySize {
+			return nil, "", fmt.Errorf("invalid ed25519 key")
+		}
+		return ed25519.PublicKey(x), EdDSA, nil
+	case "oct":
+		secret, err := dec.DecodeString(k.K)
+		if err != nil || len(secret) == 0 {
+			return nil, "", fmt.Errorf("invalid oct key")
+		}
+		return secret, HS256, nil
+	}
+	return nil, "", fmt.Errorf("unsupported kty %q", k.Kty)
+}
+
+// JWKSFile 从JWKS文件读取密钥，文件修改后自动重新加载，用于密钥轮换：
+// 先在文件中加入新密钥，签发方切换到新kid，旧token过期后再删除旧密钥
+type JWKSFile struct {
+	Path    string
+	Refresh time.Duration // 检查文件是否修改的间隔，默认1分钟；遇到未知的kid时立即检查
+
+	mu        sync.RWMutex
+	keys      map[string]jwkKey
+	modTime   time.Time
+	size      int64
+	checkedAt time.Time
+}
+
+// NewJWKSFile 读取path，文件不存在或格式错误时返回错误
+func NewJWKSFile(path string, refresh time.Duration) (*JWKSFile, error) {
+	f := &JWKSFile{Path: path, Refresh: refresh}
+	if err := f.reload(true); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *JWKSFile) Key(kid, alg string) (interface{}, error) {
+	refresh := f.Refresh
+	if refresh <= 0 {
+		refresh = time.Minute
+	}
+	f.mu.RLock()
+	key, ok := f.lookup(kid)
+	stale := time.Since(f.checkedAt) > refresh
+	// 未知kid时最多每秒检查一次文件，避免伪造的kid导致频繁读取
+	retry := !ok && time.Since(f.checkedAt) > time.Second
+	f.mu.RUnlock()
+
+	if stale || retry {
+		if err := f.reload(false); err != nil {
+			logger.Error("reload jwks with error", zap.Error(err), zap.String("path", f.Path))
+		}
+		f.mu.RLock()
+		key, ok = f.lookup(kid)
+		f.mu.RUnlock()
+	}
+	if !ok || key.alg != alg {
+		return nil, ErrUnknownKey
+	}
+	return key.key, nil
+}
+
+func (f *JWKSFile) lookup(kid string) (jwkKey, bool) {
+	if key, ok := f.keys[kid]; ok {
+		return key, true
+	}
+	if kid == "" && len(f.keys) == 1 {
+		for _, key := range f.keys {
+			return key, true
+		}
+	}
+	return jwkKey{}, false
+}
+
+// reload 文件的修改时间或大小变化时重新解析，解析失败时保留原来的密钥
+func (f *JWKSFile) reload(force bool) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.checkedAt = time.Now()
+	info, err := os.Stat(f.Path)
+	if err != nil {
+		return err
+	}
+	if !force && info.ModTime().Equal(f.modTime) && info.Size() == f.size {
+		return nil
+	}
+	data, err := os.ReadFile(f.Path)
+	if err != nil {
+		return err
+	}
+	keys, err := parseJWKS(data)
+	if err != nil {
+		return err
+	}
+	f.keys, f.modTime, f.size = keys, info.ModTime(), info.Size()
+	logger.Info("jwks loaded", zap.String("path", f.Path), zap.Int("keys", len(keys)))
+	return nil
+}
@@ -0,0 +1,345 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hudangwei/common/macaron/user"
+)
+
+// 支持的签名算法
+const (
+	HS256 = "HS256"
+	RS256 = "RS256"
+	EdDSA = "EdDSA"
+)
+
+// KeySet 按JWT头部的kid和alg返回验证签名的密钥：HS256为[]byte，RS256为*rsa.PublicKey，EdDSA为ed25519.PublicKey
+type KeySet interface {
+	Key(kid, alg string) (interface{}, error)
+}
+
+// Keys 固定的密钥，键为kid；JWT头部没有kid时只有一个密钥才能使用
+type Keys map[string]interface{}
+
+func (ks Keys) Key(kid, alg string) (interface{}, error) {
+	if key, ok := ks[kid]; ok {
+		return key, nil
+	}
+	if kid == "" && len(ks) == 1 {
+		for _, key := range ks {
+			return key, nil
+		}
+	}
+	return nil, ErrUnknownKey
+}
+
+// Claims JWT的payload
+type Claims map[string]interface{}
+
+// JWT 验证Authorization: Bearer <token>(或Cookie、Query中)的JWT，exp、nbf总是检查，
+// Issuer、Audience不为空时检查iss、aud
+type JWT struct {
+	Keys     KeySet
+	Header   string // 默认Authorization
+	Scheme   string // 默认Bearer
+	Cookie   string
+	Query    string
+	Issuer   string
+	Audience string
+	Leeway   time.Duration // 校验exp、nbf时允许的时钟误差
+	// ToUser 由claims生成用户，默认为ClaimsToUser
+	ToUser func(Claims) (*user.User, error)
+}
+
+func (j *JWT) Authenticate(req *http.Request) (*user.User, error) {
+	header, scheme := j.Header, j.Scheme
+	if header == "" {
+		header = "Authorization"
+	}
+	if scheme == "" {
+		scheme = "Bearer"
+	}
+	token := credential(req, header, scheme, j.Cookie, j.Query)
+	if token == "" {
+		return nil, nil
+	}
+	claims, err := j.Verify(token)
+	if err != nil {
+		return nil, err
+	}
+	toUser := j.ToUser
+	if toUser == nil {
+		toUser = ClaimsToUser
+	}
+	return toUser(claims)
+}
+
+// Verify 验证签名和时间等声明，返回payload
+func (j *JWT) Verify(token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrInvalidToken
+	}
+	var head struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &head); err != nil {
+		return nil, err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	if j.Keys == nil {
+		return nil, ErrUnknownKey
+	}
+	key, err := j.Keys.Key(head.Kid, head.Alg)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifySignature(head.Alg, key, parts[0]+"."+parts[1], sig); err != nil {
+		return nil, err
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, err
+	}
+	if err := j.checkClaims(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	bs, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return ErrInvalidToken
+	}
+	dec := json.NewDecoder(strings.NewReader(string(bs)))
+	dec.UseNumber()
+	if err := dec.Decode(v); err != nil {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+// verifySignature 密钥类型必须与alg对应，防止用RSA公钥作为HMAC密钥伪造签名
+func verifySignature(alg string, key interface{}, signed string, sig []byte) error {
+	switch alg {
+	case HS256:
+		secret, ok := key.([]byte)
+		if !ok {
+			return ErrUnknownKey
+		}
+		mac := hmac.New(sha256.New, secret)
+		mac.Write([]byte(signed))
+		if !hmac.Equal(mac.Sum(nil), sig) {
+			return ErrInvalidToken
+		}
+	case RS256:
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return ErrUnknownKey
+		}
+		sum := sha256.Sum256([]byte(signed))
+		if rsa.VerifyPKCS1v15(pub, crypto.SHA256, sum[:], sig) != nil {
+			return ErrInvalidToken
+		}
+	case EdDSA:
+		pub, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return ErrUnknownKey
+		}
+		if !ed25519.Verify(pub, []byte(signed), sig) {
+			return ErrInvalidToken
+		}
+	default:
+		return fmt.Errorf("%w: unsupported alg %q", ErrInvalidToken, alg)
+	}
+	return nil
+}
+
+func (j *JWT) checkClaims(claims Claims) error {
+	now := time.Now()
+	exp, hasExp, err := claims.numericDate("exp")
+	if err != nil {
+		return err
+	}
+	if hasExp && now.After(time.Unix(exp, 0).Add(j.Leeway)) {
+		return ErrTokenExpired
+	}
+	nbf, hasNbf, err := claims.numericDate("nbf")
+	if err != nil {
+		return err
+	}
+	if hasNbf && now.Add(j.Leeway).Before(time.Unix(nbf, 0)) {
+		return fmt.Errorf("%w: token not valid yet", ErrInvalidToken)
+	}
+	if j.Issuer != "" && claims.String("iss") != j.Issuer {
+		return fmt.Errorf("%w: unexpected issuer", ErrInvalidToken)
+	}
+	if j.Audience != "" && !claims.hasAudience(j.Audience) {
+		return fmt.Errorf("%w: unexpected audience", ErrInvalidToken)
+	}
+	return nil
+}
+
+func (c Claims) String(name string) string {
+	switch v := c[name].(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	}
+	return ""
+}
+
+func (c Claims) Int(name string) (int64, bool) {
+	switch v := c[name].(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i, true
+		}
+		if f, err := v.Float64(); err == nil {
+			return int64(f), true
+		}
+	case float64:
+		return int64(v), true
+	case int64:
+		return v, true
+	case int:
+		return int64(v), true
+	case string:
+		if i, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// numericDate 按RFC 7519读取时间声明，必须是数字；存在但不是数字时返回ErrInvalidClaims，避免跳过检查
+func (c Claims) numericDate(name string) (int64, bool, error) {
+	v, ok := c[name]
+	if !ok {
+		return 0, false, nil
+	}
+	switch v.(type) {
+	case json.Number, float64, int64, int:
+		if t, ok := c.Int(name); ok {
+			return t, true, nil
+		}
+	}
+	return 0, false, fmt.Errorf("%w: %s is not a numeric date", ErrInvalidClaims, name)
+}
+
+// Strings 字符串数组，也接受单个字符串和空格分隔的字符串(如scope)
+func (c Claims) Strings(name string) []string {
+	switch v := c[name].(type) {
+	case string:
+		return strings.Fields(v)
+	case []interface{}:
+		ss := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				ss = append(ss, s)
+			}
+		}
+		return ss
+	case []string:
+		return v
+	}
+	return nil
+}
+
+func (c Claims) hasAudience(aud string) bool {
+	if s, ok := c["aud"].(string); ok {
+		return s == aud
+	}
+	for _, s := range c.Strings("aud") {
+		if s == aud {
+			return true
+		}
+	}
+	return false
+}
+
+// ClaimsToUser 默认的claims到用户的映射：uid(或数字的sub)、sub、username(或name)、avatar、roles、sid
+func ClaimsToUser(claims Claims) (*user.User, error) {
+	u := &user.User{
+		UserId:    claims.String("sub"),
+		UserName:  claims.String("username"),
+		Avatar:    claims.String("avatar"),
+		SessionId: claims.String("sid"),
+		Roles:     claims.Strings("roles"),
+	}
+	if u.UserName == "" {
+		u.UserName = claims.String("name")
+	}
+	if uid, ok := claims.Int("uid"); ok {
+		u.Uid = uid
+	} else if uid, err := strconv.ParseInt(u.UserId, 10, 64); err == nil {
+		u.Uid = uid
+	}
+	if u.Uid == 0 && u.UserId == "" {
+		return nil, fmt.Errorf("%w: missing sub", ErrInvalidToken)
+	}
+	return u, nil
+}
+
+// Sign 生成JWT，key为[]byte(HS256)、*rsa.PrivateKey(RS256)或ed25519.PrivateKey(EdDSA)
+func Sign(claims Claims, alg, kid string, key interface{}) (string, error) {
+	head := map[string]string{"alg": alg, "typ": "JWT"}
+	if kid != "" {
+		head["kid"] = kid
+	}
+	hb, err := json.Marshal(head)
+	if err != nil {
+		return "", err
+	}
+	cb, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signed := base64.RawURLEncoding.EncodeToString(hb) + "." + base64.RawURLEncoding.EncodeToString(cb)
+
+	var sig []byte
+	switch k := key.(type) {
+	case []byte:
+		if alg != HS256 {
+			return "", ErrUnknownKey
+		}
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(signed))
+		sig = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		if alg != RS256 {
+			return "", ErrUnknownKey
+		}
+		sum := sha256.Sum256([]byte(signed))
+		if sig, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, sum[:]); err != nil {
+			return "", err
+		}
+	case ed25519.PrivateKey:
+		if alg != EdDSA {
+			return "", ErrUnknownKey
+		}
+		sig = ed25519.Sign(k, []byte(signed))
+	default:
+		return "", ErrUnknownKey
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	redigo "github.com/gomodule/redigo/redis"
+	"github.com/hudangwei/common/macaron/user"
+)
+
+// RedisClient Session用到的redis.Redis的方法
+type RedisClient interface {
+	Get(key string) (interface{}, error)
+	SetEx(key string, value interface{}, timeout int64) error
+	Expire(key string, duration int64) (int64, error)
+	Del(keys ...string) (int64, error)
+}
+
+// Session Redis中保存的会话，键为 KeyPrefix+会话ID，值为user.User的json；
+// 会话ID依次从Cookie、Header读取，识别出的用户的SessionId为会话ID
+type Session struct {
+	Redis     RedisClient
+	KeyPrefix string // 默认session:
+	Cookie    string // 默认session_id
+	Header    string // 默认X-Session-Id
+	TTL       int64  // 会话的过期时间(秒)，大于0时每次访问后重新计算
+}
+
+func (s *Session) key(sid string) string {
+	prefix := s.KeyPrefix
+	if prefix == "" {
+		prefix = "session:"
+	}
+	return prefix + sid
+}
+
+func (s *Session) Authenticate(req *http.Request) (*user.User, error) {
+	cookie, header := s.Cookie, s.Header
+	if cookie == "" {
+		cookie = "session_id"
+	}
+	if header == "" {
+		header = "X-Session-Id"
+	}
+	sid := credential(req, "", "", cookie, "")
+	if sid == "" {
+		sid = credential(req, header, "", "", "")
+	}
+	if sid == "" {
+		return nil, nil
+	}
+	u, err := s.Load(sid)
+	if err != nil {
+		return nil, err
+	}
+	if s.TTL > 0 {
+		s.Redis.Expire(s.key(sid), s.TTL)
+	}
+	return u, nil
+}
+
+// Load 读取会话，会话不存在或已过期时返回ErrInvalidSession
+func (s *Session) Load(sid string) (*user.User, error) {
+	bs, err := redigo.Bytes(s.Redis.Get(s.key(sid)))
+	if errors.Is(err, redigo.ErrNil) {
+		return nil, ErrInvalidSession
+	}
+	if err != nil {
+		return nil, err
+	}
+	u := &user.User{}
+	if err := json.Unmarshal(bs, u); err != nil {
+		return nil, ErrInvalidSession
+	}
+	u.SessionId = sid
+	return u, nil
+}
+
+// Create 为u创建会话，返回随机生成的会话ID，同时设置u.SessionId；TTL为0时会话不过期
+func (s *Session) Create(u *user.User) (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	sid := hex.EncodeToString(b)
+	u.SessionId = sid
+	bs, err := json.Marshal(u)
+	if err != nil {
+		return "", err
+	}
+	ttl := s.TTL
+	if ttl <= 0 {
+		// SETEX不支持不过期，使用较长的时间
+		ttl = 10 * 365 * 24 * 3600
+	}
+	if err := s.Redis.SetEx(s.key(sid), bs, ttl); err != nil {
+		return "", err
+	}
+	return sid, nil
+}
+
+// Delete 删除会话，用于退出登录
+func (s *Session) Delete(sid string) error {
+	_, err := s.Redis.Del(s.key(sid))
+	return err
+}
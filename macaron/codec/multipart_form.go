@@ -1,60 +0,0 @@
-package codec
-
-import (
-	"bytes"
-	"fmt"
-	"io"
-	"io/ioutil"
-	"net/http"
-
-	"github.com/hudangwei/common/macaron/util"
-)
-
-type MultipartForm struct {
-}
-
-func (j *MultipartForm) ContentType() []string {
-	return []string{"multipart/form-data"}
-}
-
-func (j *MultipartForm) UnmarshalSearchMap(req *http.Request) (SearchMap, error) {
-	// make request body could read multiple
-	bs, err := util.CopyBody(req)
-	if err != nil {
-		return nil, err
-	}
-
-	reader, err := req.MultipartReader()
-	if err != nil {
-		return nil, fmt.Errorf("read form-data input from body failed: %s", err)
-	}
-
-	multiParts := SearchMap{}
-	for {
-		p, err := reader.NextPart()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return nil, fmt.Errorf("read next part from body failed: %s", err)
-		}
-
-		bs, err := ioutil.ReadAll(p)
-		if err != nil {
-			return nil, fmt.Errorf("read part body from body failed: %s", err)
-		}
-		if p.FileName() != "" {
-			fmt.Println("multiparts filename", p.FileName(), p.FormName())
-			multiParts[fmt.Sprintf("%s%s", "_", p.FormName())] = []byte(p.FileName())
-		}
-
-		multiParts[p.FormName()] = bs
-	}
-
-	req.Body = ioutil.NopCloser(bytes.NewBuffer(bs))
-	return multiParts, nil
-}
-
-func (j *MultipartForm) Marshal(ptr interface{}) ([]byte, error) {
-	panic("MultipartForm not support marshal")
-}
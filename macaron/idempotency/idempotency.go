@@ -0,0 +1,326 @@
+// Package idempotency 按Idempotency-Key请求头保证POST/PUT/PATCH请求只执行一次，
+// 重试时回放第一次的响应，记录可保存在Redis或cache.Cache中
+package idempotency
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/hudangwei/common/binding"
+	"github.com/hudangwei/common/logger"
+	"github.com/hudangwei/common/macaron"
+	"github.com/hudangwei/common/macaron/middleware"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrInvalidKey 请求头过长
+	ErrInvalidKey = macaron.NewError("error", "invalid idempotency key", http.StatusBadRequest)
+	// ErrKeyReused 相同的key用于不同的请求
+	ErrKeyReused = macaron.NewError("error", "idempotency key reused with a different request", http.StatusConflict)
+	// ErrInFlight 相同key的请求正在处理
+	ErrInFlight = macaron.NewError("error", "request with the same idempotency key is in progress", http.StatusConflict)
+	// ErrBodyTooLarge 计算指纹时请求体超过MaxRequestBody
+	ErrBodyTooLarge = macaron.NewError("error", binding.ErrBodyTooLarge.Error(), http.StatusRequestEntityTooLarge)
+)
+
+// ReplayedHeader 回放的响应带有此响应头
+const ReplayedHeader = "Idempotent-Replayed"
+
+// ScopeFunc key的作用域，不同作用域的相同key互不影响
+type ScopeFunc func(ctx *macaron.Context) string
+
+// ByUser 按登录用户，未登录的请求共用一个作用域
+func ByUser(ctx *macaron.Context) string {
+	if ctx.User.Uid != 0 {
+		return "uid:" + strconv.FormatInt(ctx.User.Uid, 10)
+	}
+	if ctx.User.UserId != "" {
+		return "user:" + ctx.User.UserId
+	}
+	return ""
+}
+
+// Idempotency 中间件的配置，Store必须设置；Store出错时按普通请求处理并记录日志。
+// 请求指纹为方法、路径和参数：已由middleware.Parse绑定时为绑定后的参数，否则为请求体。
+// 应在Parse之后注册(m.Use默认如此)，在Parse之前时需要把请求体读入内存，大小受MaxRequestBody限制
+type Idempotency struct {
+	Store          Store
+	Header         string        // 默认Idempotency-Key
+	TTL            time.Duration // 响应的保存时间，默认24小时
+	LockTTL        time.Duration // 处理中状态的保存时间，需大于接口的最长耗时，默认1分钟
+	Wait           time.Duration // 相同key的请求正在处理时等待的时间，超时返回ErrInFlight，默认不等待
+	MaxBody        int           // 可保存的最大响应体，超过时不保存，默认1MB
+	MaxRequestBody int64         // 在Parse之前计算指纹时读取的请求体上限，超过时返回413，默认binding.DefaultMaxBodySize
+	Scope          ScopeFunc     // 默认ByUser
+}
+
+// record 保存的请求记录，Done为false时表示正在处理，Token区分持有处理权的请求
+type record struct {
+	Fingerprint string      `json:"fingerprint"`
+	Token       string      `json:"token,omitempty"`
+	Done        bool        `json:"done"`
+	Status      int         `json:"status,omitempty"`
+	Header      http.Header `json:"header,omitempty"`
+	Body        []byte      `json:"body,omitempty"`
+}
+
+const maxKeyLen = 255
+
+// New 返回使用store的中间件，如 m.Use(idempotency.New(&idempotency.RedisStore{Redis: r}))
+func New(store Store) macaron.Handler {
+	return (&Idempotency{Store: store}).Handler()
+}
+
+func (o *Idempotency) Handler() macaron.Handler {
+	if o.Store == nil {
+		panic("idempotency: store is required")
+	}
+	header, ttl, lockTTL, maxBody, maxReqBody, scopeOf := o.Header, o.TTL, o.LockTTL, o.MaxBody, o.MaxRequestBody, o.Scope
+	if header == "" {
+		header = "Idempotency-Key"
+	}
+	if ttl <= 0 {
+		ttl = 24 * time.Hour
+	}
+	if lockTTL <= 0 {
+		lockTTL = time.Minute
+	}
+	if maxBody <= 0 {
+		maxBody = 1 << 20
+	}
+	if maxReqBody <= 0 {
+		maxReqBody = binding.DefaultMaxBodySize
+	}
+	if maxReqBody <= 0 {
+		maxReqBody = 10 << 20
+	}
+	if scopeOf == nil {
+		scopeOf = ByUser
+	}
+
+	return func(ctx *macaron.Context) int {
+		switch ctx.Req.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch:
+		default:
+			return 0
+		}
+		key := ctx.Req.Header.Get(header)
+		if key == "" {
+			return 0
+		}
+		if len(key) > maxKeyLen {
+			return middleware.AbortWith(ctx, ErrInvalidKey)
+		}
+		fp, err := fingerprint(ctx, maxReqBody)
+		if err != nil {
+			logger.Warn("idempotency fingerprint with error", zap.Error(err))
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				return middleware.AbortWith(ctx, ErrBodyTooLarge)
+			}
+			return middleware.AbortWith(ctx, ErrInvalidKey)
+		}
+		key = "idempotency:" + scopeOf(ctx) + ":" + key
+
+		rec, pending, err := o.acquire(key, fp, lockTTL)
+		if err != nil {
+			logger.Error("idempotency store with error", zap.Error(err), zap.String("key", key))
+			return 0
+		}
+		if pending == nil {
+			switch {
+			case rec.Fingerprint != fp:
+				return middleware.AbortWith(ctx, ErrKeyReused)
+			case !rec.Done:
+				return middleware.AbortWith(ctx, ErrInFlight)
+			}
+			replay(ctx.RespWriter, rec)
+			return macaron.Abort
+		}
+
+		rw := &recorder{ResponseWriter: ctx.RespWriter, before: ctx.RespWriter.Header().Clone(), max: maxBody}
+		ctx.RespWriter = rw
+		saved := false
+		defer func() {
+			ctx.RespWriter = rw.ResponseWriter
+			// 未保存响应(panic、5xx或响应体过大)时删除自己的处理中状态，允许客户端重试
+			if !saved {
+				if _, err := o.Store.CompareAndDelete(key, pending); err != nil {
+					logger.Error("idempotency store with error", zap.Error(err), zap.String("key", key))
+				}
+			}
+		}()
+		ctx.Next()
+
+		status := rw.statusCode()
+		if status >= http.StatusInternalServerError || rw.overflow {
+			return 0
+		}
+		bs, err := json.Marshal(&record{Fingerprint: fp, Done: true, Status: status, Header: rw.header, Body: rw.body.Bytes()})
+		if err == nil {
+			saved, err = o.Store.CompareAndSet(key, pending, bs, ttl)
+		}
+		if err != nil {
+			logger.Error("idempotency store with error", zap.Error(err), zap.String("key", key))
+		} else if !saved {
+			// 处理时间超过LockTTL，处理权已被重试的请求抢占，不覆盖其记录
+			logger.Warn("idempotency lock expired before the response was saved", zap.String("key", key))
+		}
+		return 0
+	}
+}
+
+// acquire 写入带随机token的处理中状态并返回写入的值，之后只有值相同时才能修改或删除；
+// 已有记录时返回该记录，记录正在处理时最多等待Wait
+func (o *Idempotency) acquire(key, fp string, lockTTL time.Duration) (*record, []byte, error) {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return nil, nil, err
+	}
+	pending, err := json.Marshal(&record{Fingerprint: fp, Token: hex.EncodeToString(token)})
+	if err != nil {
+		return nil, nil, err
+	}
+	deadline := time.Now().Add(o.Wait)
+	for {
+		ok, err := o.Store.SetNX(key, pending, lockTTL)
+		if err != nil {
+			return nil, nil, err
+		}
+		if ok {
+			return nil, pending, nil
+		}
+		bs, err := o.Store.Get(key)
+		if err != nil {
+			return nil, nil, err
+		}
+		if bs == nil {
+			continue // 记录在SetNX之后被删除或过期，重新抢占
+		}
+		rec := &record{}
+		if err := json.Unmarshal(bs, rec); err != nil {
+			return nil, nil, err
+		}
+		if rec.Done || rec.Fingerprint != fp || time.Now().After(deadline) {
+			return rec, nil, nil
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// fingerprint 请求的方法、路径和参数的sha256
+func fingerprint(ctx *macaron.Context, maxBody int64) (string, error) {
+	var params []byte
+	var err error
+	if input := boundInput(ctx); input.IsValid() {
+		params, err = json.Marshal(input.Interface())
+	} else {
+		params, err = readBody(ctx, maxBody)
+	}
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	h.Write([]byte(ctx.Req.Method + " " + ctx.Req.URL.RequestURI() + "\n"))
+	h.Write(params)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// readBody 读取最多maxBody字节的请求体，之后的handler可以再次读取
+func readBody(ctx *macaron.Context, maxBody int64) ([]byte, error) {
+	bs, err := io.ReadAll(http.MaxBytesReader(ctx.RespWriter, ctx.Req.Body, maxBody))
+	if err != nil {
+		return nil, err
+	}
+	ctx.Req.Body = io.NopCloser(bytes.NewReader(bs))
+	return bs, nil
+}
+
+// boundInput middleware.Parse绑定的参数，请求体此时已被读取
+func boundInput(ctx *macaron.Context) reflect.Value {
+	if ctx.InputType == nil {
+		return reflect.Value{}
+	}
+	return ctx.GetVal(reflect.PtrTo(ctx.InputType))
+}
+
+func replay(rw http.ResponseWriter, rec *record) {
+	h := rw.Header()
+	for k, v := range rec.Header {
+		h[k] = v
+	}
+	h.Set(ReplayedHeader, "true")
+	rw.WriteHeader(rec.Status)
+	if _, err := rw.Write(rec.Body); err != nil {
+		logger.Warn("write replayed response with error", zap.Error(err))
+	}
+}
+
+// recorder 转发写入的同时记录状态码、handler设置的响应头和响应体
+type recorder struct {
+	http.ResponseWriter
+	before   http.Header // 进入handler前已有的响应头，如限流信息，不需要回放
+	status   int
+	header   http.Header
+	body     bytes.Buffer
+	max      int
+	overflow bool
+}
+
+func (r *recorder) WriteHeader(code int) {
+	if r.status == 0 {
+		r.status = code
+		r.header = http.Header{}
+		for k, v := range r.ResponseWriter.Header() {
+			if old, ok := r.before[k]; !ok || !equal(old, v) {
+				r.header[k] = v
+			}
+		}
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.WriteHeader(http.StatusOK)
+	}
+	if !r.overflow {
+		if r.body.Len()+len(b) > r.max {
+			r.overflow = true
+			r.body.Reset()
+		} else {
+			r.body.Write(b)
+		}
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// statusCode handler未写入时为200
+func (r *recorder) statusCode() int {
+	if r.status == 0 {
+		r.WriteHeader(http.StatusOK)
+	}
+	return r.status
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
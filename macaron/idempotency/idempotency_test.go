@@ -0,0 +1,297 @@
+package idempotency
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hudangwei/common/cache"
+	"github.com/hudangwei/common/logger"
+	"github.com/hudangwei/common/macaron"
+	"github.com/hudangwei/common/macaron/middleware"
+	"github.com/hudangwei/common/redis"
+)
+
+var _ RedisClient = (*redis.Redis)(nil)
+
+// TestMain 日志写到临时目录，避免在包目录下生成logs
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "idempotency-logs")
+	if err != nil {
+		panic(err)
+	}
+	logger.Init(dir, "server.log", "debug")
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+type order struct {
+	Item string `json:"item"`
+}
+
+func newStore(t *testing.T) *CacheStore {
+	c, err := cache.NewCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return &CacheStore{Cache: c}
+}
+
+func TestCacheStore(t *testing.T) {
+	testStore(t, newStore(t))
+	s := newStore(t)
+	s.set("k", []byte("c"), -time.Second)
+	if v, err := s.Get("k"); v != nil || err != nil {
+		t.Fatalf("expired: %q %v", v, err)
+	}
+}
+
+func TestRedisStore(t *testing.T) {
+	testStore(t, &RedisStore{Redis: fakeRedis{}})
+}
+
+func testStore(t *testing.T, s Store) {
+	if ok, err := s.SetNX("k", []byte("a"), time.Minute); !ok || err != nil {
+		t.Fatalf("setnx: %v %v", ok, err)
+	}
+	if ok, _ := s.SetNX("k", []byte("b"), time.Minute); ok {
+		t.Fatal("setnx on existing key")
+	}
+	if v, _ := s.Get("k"); string(v) != "a" {
+		t.Fatalf("get = %q", v)
+	}
+	if ok, err := s.CompareAndSet("k", []byte("x"), []byte("c"), time.Minute); ok || err != nil {
+		t.Fatalf("cas with other value: %v %v", ok, err)
+	}
+	if ok, err := s.CompareAndDelete("k", []byte("x")); ok || err != nil {
+		t.Fatalf("cad with other value: %v %v", ok, err)
+	}
+	if ok, err := s.CompareAndSet("k", []byte("a"), []byte("c"), time.Minute); !ok || err != nil {
+		t.Fatalf("cas: %v %v", ok, err)
+	}
+	if v, _ := s.Get("k"); string(v) != "c" {
+		t.Fatalf("get after cas = %q", v)
+	}
+	if ok, err := s.CompareAndDelete("k", []byte("c")); !ok || err != nil {
+		t.Fatalf("cad: %v %v", ok, err)
+	}
+	if v, err := s.Get("k"); v != nil || err != nil {
+		t.Fatalf("deleted: %q %v", v, err)
+	}
+	if ok, err := s.CompareAndSet("k", []byte("c"), []byte("d"), time.Minute); ok || err != nil {
+		t.Fatalf("cas on missing key: %v %v", ok, err)
+	}
+}
+
+// fakeRedis 用map实现RedisStore用到的命令，EVAL按脚本执行相同的比较
+type fakeRedis map[string]string
+
+func (f fakeRedis) Do(command string, args ...interface{}) (interface{}, error) {
+	str := func(v interface{}) string {
+		if bs, ok := v.([]byte); ok {
+			return string(bs)
+		}
+		return fmt.Sprint(v)
+	}
+	switch command {
+	case "GET":
+		if v, ok := f[str(args[0])]; ok {
+			return []byte(v), nil
+		}
+		return nil, nil
+	case "SET":
+		key := str(args[0])
+		if _, ok := f[key]; ok && len(args) > 4 && args[4] == "NX" {
+			return nil, nil
+		}
+		f[key] = str(args[1])
+		return "OK", nil
+	case "EVAL":
+		key := str(args[2])
+		if v, ok := f[key]; !ok || v != str(args[3]) {
+			return int64(0), nil
+		}
+		switch args[0] {
+		case compareAndSetScript:
+			f[key] = str(args[4])
+		case compareAndDeleteScript:
+			delete(f, key)
+		default:
+			return nil, errors.New("unknown script")
+		}
+		return int64(1), nil
+	}
+	return nil, fmt.Errorf("unknown command %s", command)
+}
+
+func TestIdempotency(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var calls int32
+	release := make(chan struct{})
+	m := macaron.New()
+	m.Map(middleware.HTTPResp())
+	m.Use(func(ctx *macaron.Context) { ctx.RespWriter.Header().Set("RateLimit-Remaining", "9") })
+	m.Use(middleware.Parse())
+	m.Use(New(newStore(t)))
+
+	r := gin.New()
+	r.POST("/orders", m.Wraps(func(ctx *macaron.Context, in *order) interface{} {
+		n := atomic.AddInt32(&calls, 1)
+		if in.Item == "slow" {
+			<-release
+		}
+		if in.Item == "fail" {
+			return macaron.NewError("error", "failed", http.StatusInternalServerError)
+		}
+		ctx.RespWriter.Header().Set("Location", "/orders/"+in.Item)
+		return map[string]interface{}{"id": n, "item": in.Item}
+	}))
+
+	do := func(key, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	first := do("k1", `{"item":"book"}`)
+	replayed := do("k1", `{"item": "book"}`)
+	if first.Code != http.StatusOK || replayed.Code != http.StatusOK || replayed.Body.String() != first.Body.String() {
+		t.Fatalf("replay: %d %s, %d %s", first.Code, first.Body, replayed.Code, replayed.Body)
+	}
+	if replayed.Header().Get(ReplayedHeader) != "true" || replayed.Header().Get("Location") != "/orders/book" {
+		t.Errorf("replay headers = %v", replayed.Header())
+	}
+	if first.Header().Get(ReplayedHeader) != "" {
+		t.Errorf("first headers = %v", first.Header())
+	}
+	if calls != 1 {
+		t.Fatalf("calls = %d", calls)
+	}
+	if w := do("k1", `{"item":"pen"}`); w.Code != http.StatusConflict {
+		t.Errorf("reused key: status = %d", w.Code)
+	}
+	if w := do("", `{"item":"book"}`); w.Code != http.StatusOK || calls != 2 {
+		t.Errorf("without key: status = %d, calls = %d", w.Code, calls)
+	}
+
+	// 5xx不保存，重试时重新执行
+	do("k2", `{"item":"fail"}`)
+	do("k2", `{"item":"fail"}`)
+	if calls != 4 {
+		t.Errorf("failed request: calls = %d", calls)
+	}
+
+	// 相同key的请求正在处理时返回409，处理完成后回放
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- do("k3", `{"item":"slow"}`) }()
+	for atomic.LoadInt32(&calls) != 5 {
+		time.Sleep(time.Millisecond)
+	}
+	if w := do("k3", `{"item":"slow"}`); w.Code != http.StatusConflict {
+		t.Errorf("in flight: status = %d", w.Code)
+	}
+	close(release)
+	if w := <-done; w.Code != http.StatusOK {
+		t.Fatalf("slow: status = %d", w.Code)
+	}
+	if w := do("k3", `{"item":"slow"}`); w.Code != http.StatusOK || w.Header().Get(ReplayedHeader) != "true" || calls != 5 {
+		t.Errorf("after in flight: status = %d, calls = %d", w.Code, calls)
+	}
+}
+
+// 处理时间超过LockTTL时重试的请求抢占处理权，第一个请求完成后不覆盖其记录
+func TestIdempotencyLockExpired(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var calls int32
+	release := make(chan struct{})
+	m := macaron.New()
+	m.Map(middleware.HTTPResp())
+	m.Use(middleware.Parse())
+	m.Use((&Idempotency{Store: newStore(t), LockTTL: 20 * time.Millisecond}).Handler())
+
+	r := gin.New()
+	r.POST("/orders", m.Wraps(func(ctx *macaron.Context, in *order) interface{} {
+		n := atomic.AddInt32(&calls, 1)
+		if n == 1 {
+			<-release
+		}
+		return map[string]interface{}{"id": n}
+	}))
+	do := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(`{"item":"book"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Idempotency-Key", "k1")
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- do() }()
+	for atomic.LoadInt32(&calls) != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	second := do()
+	if second.Code != http.StatusOK || !strings.Contains(second.Body.String(), `"id":2`) {
+		t.Fatalf("second: %d %s", second.Code, second.Body)
+	}
+	close(release)
+	if w := <-done; !strings.Contains(w.Body.String(), `"id":1`) {
+		t.Fatalf("first: %d %s", w.Code, w.Body)
+	}
+	if w := do(); w.Body.String() != second.Body.String() || w.Header().Get(ReplayedHeader) != "true" || calls != 2 {
+		t.Errorf("replay: %s, calls = %d", w.Body, calls)
+	}
+}
+
+func TestIdempotencyRawBody(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var calls int32
+	m := macaron.New()
+	m.Map(middleware.HTTPResp())
+	m.Use((&Idempotency{Store: newStore(t), MaxRequestBody: 16}).Handler())
+
+	r := gin.New()
+	r.POST("/raw", m.Wraps(func(ctx *macaron.Context) interface{} {
+		bs, _ := io.ReadAll(ctx.Req.Body)
+		atomic.AddInt32(&calls, 1)
+		return map[string]interface{}{"body": string(bs)}
+	}))
+	do := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/raw", strings.NewReader(body))
+		req.Header.Set("Idempotency-Key", "k1")
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do(strings.Repeat("x", 17)); w.Code != http.StatusRequestEntityTooLarge || calls != 0 {
+		t.Fatalf("too large: status = %d, calls = %d", w.Code, calls)
+	}
+	first := do("hello")
+	if first.Code != http.StatusOK || !strings.Contains(first.Body.String(), `"body":"hello"`) {
+		t.Fatalf("first: %d %s", first.Code, first.Body)
+	}
+	if w := do("hello"); w.Body.String() != first.Body.String() || calls != 1 {
+		t.Errorf("replay: %s, calls = %d", w.Body, calls)
+	}
+	if w := do("world"); w.Code != http.StatusConflict {
+		t.Errorf("different body: status = %d", w.Code)
+	}
+}
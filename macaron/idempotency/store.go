@@ -0,0 +1,135 @@
+package idempotency
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"sync"
+	"time"
+
+	redigo "github.com/gomodule/redigo/redis"
+	"github.com/hudangwei/common/cache"
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+// Store 保存请求记录，SetNX用于抢占处理权，需要保证并发安全；
+// CompareAndSet和CompareAndDelete只在当前值与old相同时修改，处理权过期被其他请求抢占后不会覆盖其记录
+type Store interface {
+	// Get 不存在或已过期时返回nil, nil
+	Get(key string) ([]byte, error)
+	// SetNX key不存在时写入并返回true
+	SetNX(key string, value []byte, ttl time.Duration) (bool, error)
+	// CompareAndSet 当前值为old时写入value并返回true
+	CompareAndSet(key string, old, value []byte, ttl time.Duration) (bool, error)
+	// CompareAndDelete 当前值为old时删除并返回true
+	CompareAndDelete(key string, old []byte) (bool, error)
+}
+
+// RedisClient RedisStore用到的redis.Redis的方法
+type RedisClient interface {
+	Do(command string, args ...interface{}) (interface{}, error)
+}
+
+// RedisStore 集群使用的Redis存储，SetNX即 SET key value PX ttl NX，比较和修改在Lua脚本中原子完成
+type RedisStore struct {
+	Redis RedisClient
+}
+
+const compareAndSetScript = `
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	redis.call('SET', KEYS[1], ARGV[2], 'PX', ARGV[3])
+	return 1
+end
+return 0
+`
+
+const compareAndDeleteScript = `
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`
+
+func (s *RedisStore) Get(key string) ([]byte, error) {
+	v, err := redigo.Bytes(s.Redis.Do("GET", key))
+	if err == redigo.ErrNil {
+		return nil, nil
+	}
+	return v, err
+}
+
+func (s *RedisStore) SetNX(key string, value []byte, ttl time.Duration) (bool, error) {
+	reply, err := s.Redis.Do("SET", key, value, "PX", ttl.Milliseconds(), "NX")
+	if err != nil {
+		return false, err
+	}
+	return reply != nil, nil
+}
+
+func (s *RedisStore) CompareAndSet(key string, old, value []byte, ttl time.Duration) (bool, error) {
+	n, err := redigo.Int(s.Redis.Do("EVAL", compareAndSetScript, 1, key, old, value, ttl.Milliseconds()))
+	return n == 1, err
+}
+
+func (s *RedisStore) CompareAndDelete(key string, old []byte) (bool, error) {
+	n, err := redigo.Int(s.Redis.Do("EVAL", compareAndDeleteScript, 1, key, old))
+	return n == 1, err
+}
+
+// CacheStore 单实例使用的cache.Cache存储，过期时间保存在值的前8个字节，读取时删除过期的记录
+type CacheStore struct {
+	Cache *cache.Cache
+
+	mu sync.Mutex // 检查和修改需要互斥
+}
+
+func (s *CacheStore) Get(key string) ([]byte, error) {
+	v, err := s.Cache.Get(key)
+	if errors.Is(err, leveldb.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(v) < 8 || int64(binary.BigEndian.Uint64(v)) < time.Now().UnixMilli() {
+		return nil, s.Cache.Delete(key)
+	}
+	return v[8:], nil
+}
+
+func (s *CacheStore) SetNX(key string, value []byte, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, err := s.Get(key)
+	if err != nil || v != nil {
+		return false, err
+	}
+	return true, s.set(key, value, ttl)
+}
+
+func (s *CacheStore) CompareAndSet(key string, old, value []byte, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, err := s.Get(key)
+	if err != nil || v == nil || !bytes.Equal(v, old) {
+		return false, err
+	}
+	return true, s.set(key, value, ttl)
+}
+
+func (s *CacheStore) CompareAndDelete(key string, old []byte) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, err := s.Get(key)
+	if err != nil || v == nil || !bytes.Equal(v, old) {
+		return false, err
+	}
+	return true, s.Cache.Delete(key)
+}
+
+func (s *CacheStore) set(key string, value []byte, ttl time.Duration) error {
+	v := make([]byte, 8+len(value))
+	binary.BigEndian.PutUint64(v, uint64(time.Now().Add(ttl).UnixMilli()))
+	copy(v[8:], value)
+	return s.Cache.Set(key, v)
+}
@@ -4,6 +4,7 @@ import (
 	"reflect"
 
 	"github.com/gin-gonic/gin"
+	"github.com/hudangwei/common/binding"
 	"github.com/hudangwei/common/macaron/user"
 )
 
@@ -55,6 +56,7 @@ func (m *Macaron) newGinRoute(ctx *gin.Context, handler Handler, inputType refle
 		c.User.UserId = u.UserId
 		c.User.UserName = u.UserName
 		c.User.Avatar = u.Avatar
+		c.User.Roles = u.Roles
 	}
 	c.SetParent(m)
 	c.Map(c)
@@ -63,6 +65,11 @@ func (m *Macaron) newGinRoute(ctx *gin.Context, handler Handler, inputType refle
 
 func (m *Macaron) Wraps(handler Handler) func(*gin.Context) {
 	inputType := getHandlerInput(handler)
+	if inputType != nil {
+		if err := binding.CheckInput(inputType); err != nil {
+			panic(err)
+		}
+	}
 	handler = validateAndWrapHandler(handler)
 	return func(ctx *gin.Context) {
 		m.newGinRoute(ctx, handler, inputType)
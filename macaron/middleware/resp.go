@@ -6,7 +6,9 @@ import (
 	"log"
 	"net/http"
 	"reflect"
+	"strconv"
 
+	"github.com/hudangwei/common/binding/codec"
 	"github.com/hudangwei/common/macaron"
 )
 
@@ -58,11 +60,15 @@ func HTTPResp() macaron.ReturnHandler {
 				if err != nil {
 					logWriteErrors(ctx.Req, err)
 				}
+			case *macaron.Formatted:
+				code := http.StatusOK
+				if resp, ok := v.Data.(SpecCodeHttpResponse); ok {
+					code = resp.GetStatusCode()
+				}
+				writeNegotiated(ctx, code, v.Data, v.ContentType)
 			case SpecCodeHttpResponse:
 				resp := ret.(SpecCodeHttpResponse)
-				if err := writeJsonToResp(ctx.RespWriter, resp.GetStatusCode(), resp); err != nil {
-					logWriteErrors(ctx.Req, err)
-				}
+				writeNegotiated(ctx, resp.GetStatusCode(), resp, "")
 			case SpecContentTypeHttpResponse:
 				resp := ret.(SpecContentTypeHttpResponse)
 				contentType, body := resp.GetContentType()
@@ -74,20 +80,14 @@ func HTTPResp() macaron.ReturnHandler {
 				}
 			case WriteHttpResponse:
 				resp := ret.(WriteHttpResponse).BeforeWrite()
-				if err := writeJsonToResp(ctx.RespWriter, http.StatusOK, resp); err != nil {
-					logWriteErrors(ctx.Req, err)
-				}
+				writeNegotiated(ctx, http.StatusOK, resp, "")
 			case map[string]interface{}:
 				if _, ok := v["result"]; !ok {
 					v["result"] = "ok"
 				}
-				if err := writeJsonToResp(ctx.RespWriter, http.StatusOK, v); err != nil {
-					logWriteErrors(ctx.Req, err)
-				}
+				writeNegotiated(ctx, http.StatusOK, v, "")
 			case error:
-				if err := writeJsonToResp(ctx.RespWriter, http.StatusInternalServerError, macaron.NewError("error", v.Error(), http.StatusInternalServerError)); err != nil {
-					logWriteErrors(ctx.Req, err)
-				}
+				writeNegotiated(ctx, http.StatusInternalServerError, macaron.NewError("error", v.Error(), http.StatusInternalServerError), "")
 			case []byte:
 				ctx.RespWriter.Header().Set("Content-Type", "application/json")
 
@@ -96,14 +96,94 @@ func HTTPResp() macaron.ReturnHandler {
 					logWriteErrors(ctx.Req, err)
 				}
 			default:
-				if err := writeJsonToResp(ctx.RespWriter, http.StatusOK, ret); err != nil {
-					logWriteErrors(ctx.Req, err)
-				}
+				writeNegotiated(ctx, http.StatusOK, ret, "")
 			}
 		}
 	}
 }
 
+// RespCodec 响应可以使用的格式，按请求的Accept协商，Accept为空或*/*时使用第一个，都不可接受时返回406
+var RespCodec = []codec.Interface{
+	&codec.Json{},
+	&codec.Xml{},
+	&codec.Msgpack{},
+	&codec.Protobuf{},
+}
+
+type marshalChecker interface {
+	CanMarshal(interface{}) bool
+}
+
+// negotiate forced不为空时使用指定的格式，否则按Accept选择第一个能编码data的格式；
+// 都不能使用时，仅当Accept为空或其通配范围(*/*、application/*)接受JSON时使用JSON，否则返回false
+func negotiate(req *http.Request, data interface{}, forced string) (codec.Offer, bool) {
+	if forced != "" {
+		if c := codec.Find(RespCodec, forced); c != nil {
+			return codec.Offer{Codec: c, ContentType: codec.MediaType(forced)}, true
+		}
+		return codec.Offer{}, false
+	}
+	accept := req.Header.Get("Accept")
+	for _, offer := range codec.Negotiate(RespCodec, accept) {
+		if c, ok := offer.Codec.(marshalChecker); ok && !c.CanMarshal(data) {
+			continue
+		}
+		return offer, true
+	}
+	if !codec.Acceptable(accept, jsonType) {
+		return codec.Offer{}, false
+	}
+	c := codec.Find(RespCodec, jsonType)
+	if c == nil {
+		c = &codec.Json{}
+	}
+	return codec.Offer{Codec: c, ContentType: jsonType}, true
+}
+
+const jsonType = "application/json"
+
+// writeNegotiated 按协商的格式写入响应，没有Accept可接受的格式时返回406，?pretty=1时格式化输出
+func writeNegotiated(ctx *macaron.Context, code int, data interface{}, forced string) {
+	ctx.RespWriter.Header().Add("Vary", "Accept")
+	offer, ok := negotiate(ctx.Req, data, forced)
+	if !ok {
+		msg := "no acceptable response format"
+		if forced != "" {
+			msg = "unsupported response format " + forced
+			code = http.StatusInternalServerError
+		} else {
+			code = http.StatusNotAcceptable
+		}
+		if err := writeJsonToResp(ctx.RespWriter, code, macaron.NewError("error", msg, code)); err != nil {
+			logWriteErrors(ctx.Req, err)
+		}
+		return
+	}
+
+	var bs []byte
+	var err error
+	if indenter, ok := offer.Codec.(codec.Indenter); ok && isPretty(ctx.Req) {
+		bs, err = indenter.MarshalIndent(data)
+	} else {
+		bs, err = offer.Codec.Marshal(data)
+	}
+	if err != nil {
+		logWriteErrors(ctx.Req, err)
+		ctx.RespWriter.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	ctx.RespWriter.Header().Set("Content-Type", offer.ContentType)
+	ctx.RespWriter.WriteHeader(code)
+	if _, err := ctx.RespWriter.Write(bs); err != nil {
+		logWriteErrors(ctx.Req, err)
+	}
+}
+
+func isPretty(req *http.Request) bool {
+	pretty, _ := strconv.ParseBool(req.URL.Query().Get("pretty"))
+	return pretty
+}
+
 func logWriteErrors(req *http.Request, err error) {
 	log.Println("write resp failed",
 		"err", err,
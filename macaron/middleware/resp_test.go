@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hudangwei/common/macaron"
+)
+
+func TestHTTPRespNegotiate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m := macaron.New()
+	m.Map(HTTPResp())
+	data := func() map[string]interface{} { return map[string]interface{}{"name": "bob"} }
+
+	r := gin.New()
+	r.GET("/", m.Wraps(func(ctx *macaron.Context) interface{} { return data() }))
+	r.GET("/xml", m.Wraps(func(ctx *macaron.Context) interface{} { return macaron.WithFormat("application/xml", data()) }))
+	r.GET("/csv", m.Wraps(func(ctx *macaron.Context) interface{} { return macaron.WithFormat("text/csv", data()) }))
+
+	cases := []struct {
+		path, accept string
+		status       int
+		contentType  string
+		body         string
+	}{
+		{"/", "", http.StatusOK, "application/json", `{"name":"bob","result":"ok"}`},
+		{"/", "application/xml", http.StatusOK, "application/xml", "<name>bob</name>"},
+		{"/", "text/xml;q=0.5, application/msgpack", http.StatusOK, "application/msgpack", ""},
+		// 没有可接受的格式时返回406，通配范围接受JSON时回退到JSON
+		{"/", "text/plain", http.StatusNotAcceptable, "application/json", "no acceptable response format"},
+		{"/", "text/csv, application/pdf", http.StatusNotAcceptable, "application/json", "no acceptable response format"},
+		{"/", "application/x-protobuf", http.StatusNotAcceptable, "application/json", "no acceptable response format"},
+		{"/", "text/plain, */*;q=0.1", http.StatusOK, "application/json", `"name":"bob"`},
+		{"/", "application/x-protobuf, application/*;q=0.5", http.StatusOK, "application/json", `"name":"bob"`},
+		{"/", "text/plain, application/json;q=0", http.StatusNotAcceptable, "application/json", "no acceptable response format"},
+		{"/", "application/json;q=0, application/xml", http.StatusOK, "application/xml", "<name>bob</name>"},
+		{"/?pretty=1", "", http.StatusOK, "application/json", "{\n  \"name\": \"bob\""},
+		{"/xml", "application/json", http.StatusOK, "application/xml", "<name>bob</name>"},
+		{"/csv", "", http.StatusInternalServerError, "application/json", "unsupported response format text/csv"},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, c.path, nil)
+		if c.accept != "" {
+			req.Header.Set("Accept", c.accept)
+		}
+		r.ServeHTTP(w, req)
+		if w.Code != c.status || w.Header().Get("Content-Type") != c.contentType || !strings.Contains(w.Body.String(), c.body) {
+			t.Errorf("%s %q: got %d %s %s", c.path, c.accept, w.Code, w.Header().Get("Content-Type"), w.Body)
+		}
+		if c.path == "/" && w.Header().Get("Vary") != "Accept" {
+			t.Errorf("%s %q: vary = %q", c.path, c.accept, w.Header().Get("Vary"))
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package ratelimit
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hudangwei/common/api"
+	"github.com/hudangwei/common/logger"
+	"github.com/hudangwei/common/macaron"
+	"github.com/hudangwei/common/macaron/middleware"
+	"go.uber.org/zap"
+)
+
+// KeyFunc 限流的维度，返回空字符串时不限流
+type KeyFunc func(ctx *macaron.Context) string
+
+// ByIP 按连接的对端地址，服务在代理之后时使用ByClientIP
+func ByIP(ctx *macaron.Context) string {
+	host, _, err := net.SplitHostPort(ctx.Req.RemoteAddr)
+	if err != nil {
+		return ctx.Req.RemoteAddr
+	}
+	return host
+}
+
+// ByClientIP 依次按X-Real-IP、X-Forwarded-For的第一个地址和对端地址，
+// 只有代理会覆盖这些请求头时才能使用，否则客户端可以伪造
+func ByClientIP(ctx *macaron.Context) string {
+	if ip := strings.TrimSpace(ctx.Req.Header.Get("X-Real-IP")); ip != "" {
+		return ip
+	}
+	if xff := ctx.Req.Header.Get("X-Forwarded-For"); xff != "" {
+		if ip := strings.TrimSpace(strings.Split(xff, ",")[0]); ip != "" {
+			return ip
+		}
+	}
+	return ByIP(ctx)
+}
+
+// ByUid 按登录用户，未登录时按IP
+func ByUid(ctx *macaron.Context) string {
+	if ctx.User.Uid != 0 {
+		return "uid:" + strconv.FormatInt(ctx.User.Uid, 10)
+	}
+	if ctx.User.UserId != "" {
+		return "user:" + ctx.User.UserId
+	}
+	return "ip:" + ByIP(ctx)
+}
+
+// ByAPI 按接口，通过api.Handle注册的接口为方法和完整路由路径的hash(不同路由组的相同路径分别计数)，否则为方法和请求路径的hash
+func ByAPI(ctx *macaron.Context) string {
+	if route := api.RouteFromContext(ctx.Req.Context()); route != nil {
+		return "api:" + api.ApiHash(strings.ToLower(route.Method+" "+route.Path))
+	}
+	return "api:" + api.ApiHash(strings.ToLower(ctx.Req.Method)+" "+strings.ToLower(ctx.Req.URL.Path))
+}
+
+// Compose 组合多个维度，如 Compose(ByUid, ByAPI) 限制每个用户对每个接口的请求
+func Compose(fns ...KeyFunc) KeyFunc {
+	return func(ctx *macaron.Context) string {
+		parts := make([]string, 0, len(fns))
+		for _, fn := range fns {
+			part := fn(ctx)
+			if part == "" {
+				return ""
+			}
+			parts = append(parts, part)
+		}
+		return strings.Join(parts, "|")
+	}
+}
+
+// ErrTooManyRequests 超过限制时的响应
+var ErrTooManyRequests = macaron.NewError("error", "too many requests", http.StatusTooManyRequests)
+
+// Limiter 限流中间件的配置，Store默认为进程内共享的MemoryStore，Key默认为ByIP；
+// Store出错时放行并记录日志
+type Limiter struct {
+	Rule
+	Name  string // 多个Limiter共用Store时用于区分，默认按规则生成
+	Store Store
+	Key   KeyFunc
+}
+
+var defaultStore = NewMemoryStore()
+
+// New 返回按rule和key限流的中间件，如 m.Use(ratelimit.New(ratelimit.Rule{Limit: 100, Period: time.Minute}, ratelimit.ByUid))
+func New(rule Rule, key KeyFunc) macaron.Handler {
+	return (&Limiter{Rule: rule, Key: key}).Handler()
+}
+
+func (l *Limiter) Handler() macaron.Handler {
+	if l.Limit <= 0 || l.Period < time.Millisecond {
+		panic("ratelimit: limit and period must be positive")
+	}
+	store, keyOf, name := l.Store, l.Key, l.Name
+	if store == nil {
+		store = defaultStore
+	}
+	if keyOf == nil {
+		keyOf = ByIP
+	}
+	if name == "" {
+		name = strconv.Itoa(int(l.Algorithm)) + ":" + strconv.FormatInt(l.Limit, 10) + ":" + l.Period.String()
+	}
+	policy := strconv.FormatInt(l.Limit, 10) + ";w=" + strconv.FormatInt(int64(math.Ceil(l.Period.Seconds())), 10)
+
+	return func(ctx *macaron.Context) int {
+		key := keyOf(ctx)
+		if key == "" {
+			return 0
+		}
+		r, err := store.Take("ratelimit:"+name+":"+key, l.Rule, time.Now())
+		if err != nil {
+			logger.Error("rate limit with error", zap.Error(err), zap.String("key", key))
+			return 0
+		}
+		h := ctx.RespWriter.Header()
+		h.Set("RateLimit-Policy", policy)
+		h.Set("RateLimit-Limit", strconv.FormatInt(r.Limit, 10))
+		h.Set("RateLimit-Remaining", strconv.FormatInt(r.Remaining, 10))
+		h.Set("RateLimit-Reset", seconds(r.Reset))
+		if r.Allowed {
+			return 0
+		}
+		h.Set("Retry-After", seconds(r.RetryAfter))
+		return middleware.AbortWith(ctx, ErrTooManyRequests)
+	}
+}
+
+// seconds 向上取整的秒数
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
@@ -0,0 +1,176 @@
+package ratelimit
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hudangwei/common/api"
+	"github.com/hudangwei/common/macaron"
+	"github.com/hudangwei/common/macaron/middleware"
+	"github.com/hudangwei/common/redis"
+)
+
+var _ RedisClient = (*redis.Redis)(nil)
+
+func TestTokenBucket(t *testing.T) {
+	s := NewMemoryStore()
+	rule := Rule{Algorithm: TokenBucket, Limit: 3, Period: 3 * time.Second}
+	now := time.Unix(1700000000, 0)
+	for i := 0; i < 3; i++ {
+		if r, _ := s.Take("k", rule, now); !r.Allowed || r.Remaining != int64(2-i) {
+			t.Fatalf("take %d: %+v", i, r)
+		}
+	}
+	r, _ := s.Take("k", rule, now)
+	if r.Allowed || r.RetryAfter != time.Second || r.Reset != 3*time.Second {
+		t.Fatalf("limited: %+v", r)
+	}
+	if r, _ := s.Take("k", rule, now.Add(time.Second)); !r.Allowed || r.Remaining != 0 {
+		t.Fatalf("refilled: %+v", r)
+	}
+	if r, _ := s.Take("other", rule, now); !r.Allowed {
+		t.Fatalf("other key: %+v", r)
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	s := NewMemoryStore()
+	rule := Rule{Algorithm: SlidingWindow, Limit: 4, Period: 10 * time.Second}
+	start := time.Unix(1700000000, 0)
+	for i := 0; i < 4; i++ {
+		if r, _ := s.Take("k", rule, start); !r.Allowed {
+			t.Fatalf("take %d: %+v", i, r)
+		}
+	}
+	r, _ := s.Take("k", rule, start.Add(5*time.Second))
+	if r.Allowed || r.Remaining != 0 || r.RetryAfter != 7500*time.Millisecond {
+		t.Fatalf("limited: %+v", r)
+	}
+	// 下一个窗口过了一半，上一个窗口的4个请求按2个计算
+	r, _ = s.Take("k", rule, start.Add(15*time.Second))
+	if !r.Allowed || r.Remaining != 1 {
+		t.Fatalf("next window: %+v", r)
+	}
+	if r, _ := s.Take("k", rule, start.Add(15*time.Second)); !r.Allowed {
+		t.Fatalf("next window: %+v", r)
+	}
+	if r, _ := s.Take("k", rule, start.Add(15*time.Second)); r.Allowed || r.RetryAfter != 2500*time.Millisecond {
+		t.Fatalf("next window limited: %+v", r)
+	}
+	if r, _ := s.Take("k", rule, start.Add(40*time.Second)); !r.Allowed || r.Remaining != 3 {
+		t.Fatalf("expired: %+v", r)
+	}
+}
+
+// fakeRedis 没有加载脚本，EVAL时用Go实现的相同逻辑返回结果
+type fakeRedis struct {
+	bucket bucketState
+	window windowState
+	evals  int
+}
+
+func (f *fakeRedis) Do(command string, args ...interface{}) (interface{}, error) {
+	if command == "EVALSHA" {
+		return nil, errors.New("NOSCRIPT No matching script")
+	}
+	f.evals++
+	now := args[5].(int64)
+	if args[0] == windowScript.src {
+		rule := Rule{Limit: args[3].(int64), Period: time.Duration(args[4].(int64)) * time.Millisecond}
+		allowed := int64(0)
+		if countRequest(rule, &f.window, now) {
+			allowed = 1
+		}
+		return []interface{}{allowed, f.window.start, f.window.curr, f.window.prev}, nil
+	}
+	rule := Rule{Limit: args[3].(int64), Period: time.Duration(args[6].(int64)/2) * time.Millisecond}
+	allowed := int64(0)
+	if takeToken(rule, &f.bucket, now) {
+		allowed = 1
+	}
+	return []interface{}{allowed, []byte("0")}, nil
+}
+
+func TestRedisStore(t *testing.T) {
+	f := &fakeRedis{}
+	s := &RedisStore{Redis: f}
+	now := time.Unix(1700000000, 0)
+	if r, err := s.Take("k", Rule{Limit: 1, Period: time.Second}, now); err != nil || !r.Allowed || r.Remaining != 0 {
+		t.Fatalf("bucket: %+v %v", r, err)
+	}
+	rule := Rule{Algorithm: SlidingWindow, Limit: 1, Period: time.Second}
+	if r, err := s.Take("k", rule, now); err != nil || !r.Allowed {
+		t.Fatalf("window: %+v %v", r, err)
+	}
+	if r, err := s.Take("k", rule, now); err != nil || r.Allowed || r.RetryAfter != 2*time.Second {
+		t.Fatalf("window limited: %+v %v", r, err)
+	}
+	if f.evals != 3 {
+		t.Errorf("evals = %d", f.evals)
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m := macaron.New()
+	m.Map(middleware.HTTPResp())
+	m.Use(New(Rule{Limit: 2, Period: time.Minute}, ByClientIP))
+
+	r := gin.New()
+	r.GET("/", m.Wraps(func(ctx *macaron.Context) interface{} { return macaron.OK }))
+
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Forwarded-For", "10.0.0.1, 10.0.0.2")
+		r.ServeHTTP(w, req)
+		if w.Code != want {
+			t.Fatalf("request %d: status = %d, want %d", i, w.Code, want)
+		}
+		h := w.Header()
+		if h.Get("RateLimit-Limit") != "2" || h.Get("RateLimit-Policy") != "2;w=60" {
+			t.Errorf("request %d: headers = %v", i, h)
+		}
+		if want == http.StatusTooManyRequests && (h.Get("Retry-After") != "30" || h.Get("RateLimit-Remaining") != "0") {
+			t.Errorf("request %d: headers = %v", i, h)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Forwarded-For", "10.0.0.3")
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("other ip: status = %d", w.Code)
+	}
+}
+
+func TestByAPIGroups(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	defer func(m api.APIManager, mc *macaron.Macaron) { api.DefaultAPIManager, api.DefaultMacaron = m, mc }(api.DefaultAPIManager, api.DefaultMacaron)
+	api.DefaultAPIManager = api.NewAPIs()
+	api.DefaultMacaron = macaron.New()
+	api.DefaultMacaron.Map(middleware.HTTPResp())
+	api.DefaultMacaron.Use(New(Rule{Limit: 1, Period: time.Minute}, ByAPI))
+
+	r := gin.New()
+	ok := func(ctx *macaron.Context) interface{} { return macaron.OK }
+	api.Handle(api.APITagGuest, r.Group("/v1"), http.MethodGet, "/items", ok)
+	api.Handle(api.APITagGuest, r.Group("/v2"), http.MethodGet, "/items", ok)
+
+	// 不同路由组的相同路径分别计数
+	for i, c := range []struct {
+		path   string
+		status int
+	}{{"/v1/items", http.StatusOK}, {"/v2/items", http.StatusOK}, {"/v1/items", http.StatusTooManyRequests}} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, c.path, nil))
+		if w.Code != c.status {
+			t.Errorf("request %d %s: status = %d, want %d", i, c.path, w.Code, c.status)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+// Package ratelimit 请求限流中间件，支持令牌桶和滑动窗口，状态可保存在内存或Redis中
+package ratelimit
+
+import (
+	"math"
+	"time"
+)
+
+type Algorithm int
+
+const (
+	// TokenBucket 桶容量为Limit，每Period恢复Limit个令牌，允许突发Limit个请求
+	TokenBucket Algorithm = iota
+	// SlidingWindow 任意Period长度的窗口内最多Limit个请求，按前后两个固定窗口加权估算
+	SlidingWindow
+)
+
+// Rule 限流规则，如 Rule{Algorithm: SlidingWindow, Limit: 100, Period: time.Minute}
+type Rule struct {
+	Algorithm Algorithm
+	Limit     int64
+	Period    time.Duration
+}
+
+// Result 一次请求的限流结果
+type Result struct {
+	Allowed    bool
+	Limit      int64
+	Remaining  int64
+	Reset      time.Duration // 配额完全恢复的时间
+	RetryAfter time.Duration // 被拒绝时需要等待的时间
+}
+
+// bucketState 令牌桶的状态，ts为上次更新的时间(毫秒)
+type bucketState struct {
+	tokens float64
+	ts     int64
+}
+
+// takeToken 先按经过的时间补充令牌，再取出一个；Redis的Lua脚本与此逻辑相同
+func takeToken(rule Rule, s *bucketState, now int64) bool {
+	capacity := float64(rule.Limit)
+	if s.ts == 0 {
+		s.tokens, s.ts = capacity, now
+	}
+	if now > s.ts {
+		s.tokens = math.Min(capacity, s.tokens+float64(now-s.ts)*bucketRate(rule))
+		s.ts = now
+	}
+	if s.tokens >= 1 {
+		s.tokens--
+		return true
+	}
+	return false
+}
+
+// bucketRate 每毫秒恢复的令牌数
+func bucketRate(rule Rule) float64 {
+	return float64(rule.Limit) / float64(rule.Period.Milliseconds())
+}
+
+func bucketResult(rule Rule, allowed bool, tokens float64) Result {
+	rate := bucketRate(rule)
+	r := Result{
+		Allowed:   allowed,
+		Limit:     rule.Limit,
+		Remaining: int64(tokens),
+		Reset:     millis((float64(rule.Limit) - tokens) / rate),
+	}
+	if !allowed {
+		r.RetryAfter = millis((1 - tokens) / rate)
+	}
+	return r
+}
+
+// windowState 滑动窗口的状态，start为当前固定窗口的开始时间(毫秒)
+type windowState struct {
+	start int64
+	curr  int64
+	prev  int64
+}
+
+// countRequest 切换到now所在的固定窗口后，按上一个窗口的剩余比例估算请求数；Redis的Lua脚本与此逻辑相同
+func countRequest(rule Rule, s *windowState, now int64) bool {
+	period := rule.Period.Milliseconds()
+	start := now - now%period
+	if s.start != start {
+		if s.start+period == start {
+			s.prev = s.curr
+		} else {
+			s.prev = 0
+		}
+		s.start, s.curr = start, 0
+	}
+	if estimate(rule, s, now)+1 <= float64(rule.Limit) {
+		s.curr++
+		return true
+	}
+	return false
+}
+
+func estimate(rule Rule, s *windowState, now int64) float64 {
+	period := rule.Period.Milliseconds()
+	weight := 1 - float64(now-s.start)/float64(period)
+	return float64(s.prev)*weight + float64(s.curr)
+}
+
+func windowResult(rule Rule, allowed bool, s *windowState, now int64) Result {
+	period := float64(rule.Period.Milliseconds())
+	limit := float64(rule.Limit)
+	r := Result{
+		Allowed:   allowed,
+		Limit:     rule.Limit,
+		Remaining: int64(math.Max(0, limit-estimate(rule, s, now))),
+		Reset:     millis(float64(s.start) + period - float64(now)),
+	}
+	if allowed {
+		return r
+	}
+	// 估算值降到limit-1以下的时间：先看当前窗口内上一个窗口的权重下降是否足够，否则等到下一个窗口
+	need := limit - 1 - float64(s.curr)
+	if s.prev > 0 && need >= 0 {
+		at := float64(s.start) + period*(1-need/float64(s.prev))
+		r.RetryAfter = millis(at - float64(now))
+	} else {
+		at := float64(s.start) + period + period*math.Max(0, 1-(limit-1)/float64(s.curr))
+		r.RetryAfter = millis(at - float64(now))
+	}
+	if r.RetryAfter < time.Millisecond {
+		r.RetryAfter = time.Millisecond
+	}
+	return r
+}
+
+func millis(ms float64) time.Duration {
+	if ms < 0 {
+		return 0
+	}
+	return time.Duration(math.Ceil(ms)) * time.Millisecond
+}
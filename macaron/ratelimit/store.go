@@ -0,0 +1,187 @@
+package ratelimit
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	redigo "github.com/gomodule/redigo/redis"
+)
+
+// Store 保存限流状态，Take对key计一次请求并返回结果，需要保证并发安全
+type Store interface {
+	Take(key string, rule Rule, now time.Time) (Result, error)
+}
+
+// MemoryStore 单实例使用的内存存储，过期的状态定期清理
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*memoryEntry
+	sweepAt int64
+}
+
+type memoryEntry struct {
+	bucket  bucketState
+	window  windowState
+	expires int64
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: map[string]*memoryEntry{}}
+}
+
+func (s *MemoryStore) Take(key string, rule Rule, now time.Time) (Result, error) {
+	ms := now.UnixMilli()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweep(ms)
+
+	e, ok := s.buckets[key]
+	if !ok {
+		e = &memoryEntry{}
+		s.buckets[key] = e
+	}
+	// 令牌桶Period后一定已满，滑动窗口2个Period后计数清零，之后的状态可以丢弃
+	e.expires = ms + 2*rule.Period.Milliseconds()
+	if rule.Algorithm == SlidingWindow {
+		allowed := countRequest(rule, &e.window, ms)
+		return windowResult(rule, allowed, &e.window, ms), nil
+	}
+	allowed := takeToken(rule, &e.bucket, ms)
+	return bucketResult(rule, allowed, e.bucket.tokens), nil
+}
+
+// sweep 每分钟最多清理一次过期的状态
+func (s *MemoryStore) sweep(now int64) {
+	if now < s.sweepAt {
+		return
+	}
+	s.sweepAt = now + time.Minute.Milliseconds()
+	for key, e := range s.buckets {
+		if e.expires < now {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+// RedisClient RedisStore用到的redis.Redis的方法
+type RedisClient interface {
+	Do(command string, args ...interface{}) (interface{}, error)
+}
+
+// RedisStore 集群使用的Redis存储，状态的读取和更新在Lua脚本中原子完成；
+// 时间使用调用方的时钟，各实例的时钟需要同步
+type RedisStore struct {
+	Redis RedisClient
+}
+
+// 与takeToken的逻辑相同，令牌数为小数，以字符串返回
+var bucketScript = newScript(`
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+	tokens = capacity
+	ts = now
+end
+if now > ts then
+	tokens = math.min(capacity, tokens + (now - ts) * rate)
+	ts = now
+end
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+redis.call('HMSET', KEYS[1], 'tokens', tostring(tokens), 'ts', ts)
+redis.call('PEXPIRE', KEYS[1], ARGV[4])
+return {allowed, tostring(tokens)}
+`)
+
+// 与countRequest的逻辑相同
+var windowScript = newScript(`
+local limit = tonumber(ARGV[1])
+local period = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local start = now - now % period
+local state = redis.call('HMGET', KEYS[1], 'start', 'curr', 'prev')
+local sstart = tonumber(state[1])
+local curr = tonumber(state[2]) or 0
+local prev = tonumber(state[3]) or 0
+if sstart ~= start then
+	if sstart ~= nil and sstart + period == start then
+		prev = curr
+	else
+		prev = 0
+	end
+	curr = 0
+end
+local weight = 1 - (now - start) / period
+local allowed = 0
+if prev * weight + curr + 1 <= limit then
+	curr = curr + 1
+	allowed = 1
+end
+redis.call('HMSET', KEYS[1], 'start', start, 'curr', curr, 'prev', prev)
+redis.call('PEXPIRE', KEYS[1], period * 2)
+return {allowed, start, curr, prev}
+`)
+
+func (s *RedisStore) Take(key string, rule Rule, now time.Time) (Result, error) {
+	ms := now.UnixMilli()
+	period := rule.Period.Milliseconds()
+	if rule.Algorithm == SlidingWindow {
+		vals, err := redigo.Int64s(windowScript.do(s.Redis, key, rule.Limit, period, ms))
+		if err != nil {
+			return Result{}, err
+		}
+		if len(vals) != 4 {
+			return Result{}, fmt.Errorf("unexpected script result %v", vals)
+		}
+		state := windowState{start: vals[1], curr: vals[2], prev: vals[3]}
+		return windowResult(rule, vals[0] == 1, &state, ms), nil
+	}
+
+	vals, err := redigo.Values(bucketScript.do(s.Redis, key, rule.Limit, strconv.FormatFloat(bucketRate(rule), 'g', -1, 64), ms, 2*period))
+	if err != nil {
+		return Result{}, err
+	}
+	var allowed int64
+	var tokens string
+	if _, err := redigo.Scan(vals, &allowed, &tokens); err != nil {
+		return Result{}, err
+	}
+	t, err := strconv.ParseFloat(tokens, 64)
+	if err != nil {
+		return Result{}, err
+	}
+	return bucketResult(rule, allowed == 1, t), nil
+}
+
+type script struct {
+	src  string
+	hash string
+}
+
+func newScript(src string) *script {
+	sum := sha1.Sum([]byte(src))
+	return &script{src: src, hash: hex.EncodeToString(sum[:])}
+}
+
+// do 先用EVALSHA执行，脚本未加载时改用EVAL
+func (s *script) do(r RedisClient, key string, args ...interface{}) (interface{}, error) {
+	params := append([]interface{}{s.hash, 1, key}, args...)
+	reply, err := r.Do("EVALSHA", params...)
+	if err != nil && strings.HasPrefix(err.Error(), "NOSCRIPT") {
+		params[0] = s.src
+		return r.Do("EVAL", params...)
+	}
+	return reply, err
+}
@@ -0,0 +1,114 @@
+package macaron
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"net/http"
+	"reflect"
+	"sync"
+
+	"github.com/hudangwei/common/binding"
+)
+
+// TypedHandler 强类型的处理函数，In由Parse中间件绑定和校验，返回的Out按请求的Accept编码，
+// error按ErrorHandler转换为对应状态码的响应。实现了FastInvoker，调用时不经过reflect.Call
+//
+//	api.Handle(tag, group, "POST", "/users", macaron.Typed(func(ctx *macaron.Context, in *CreateUserInput) (*User, error) {
+//		...
+//	}))
+type TypedHandler[In, Out any] func(*Context, *In) (*Out, error)
+
+// Typed 把fn包装为Handler，In必须是结构体
+func Typed[In, Out any](fn func(*Context, *In) (*Out, error)) Handler {
+	return TypedHandler[In, Out](fn)
+}
+
+func (h TypedHandler[In, Out]) Invoke(params []interface{}) ([]reflect.Value, error) {
+	ctx := params[0].(*Context)
+	out, err := h(ctx, params[1].(*In))
+	if err != nil {
+		resp := ErrorHandler(ctx, err)
+		if resp == nil {
+			return nil, nil
+		}
+		return []reflect.Value{reflect.ValueOf(resp)}, nil
+	}
+	if out == nil {
+		ctx.RespWriter.WriteHeader(http.StatusNoContent)
+		return nil, nil
+	}
+	return []reflect.Value{reflect.ValueOf(out)}, nil
+}
+
+// HandlerTypes 输入输出类型，用于生成接口文档
+func (h TypedHandler[In, Out]) HandlerTypes() (in, out reflect.Type) {
+	return reflect.TypeOf((*In)(nil)).Elem(), reflect.TypeOf((*Out)(nil)).Elem()
+}
+
+// TypesProvider 能提供输入输出类型的Handler，如TypedHandler
+type TypesProvider interface {
+	HandlerTypes() (in, out reflect.Type)
+}
+
+// HandlerTypes 返回handler的输入类型和输出类型，没有输入时in为nil；
+// 只有TypedHandler能确定输出类型，其他handler的out为nil
+func HandlerTypes(handler Handler) (in, out reflect.Type) {
+	if tp, ok := handler.(TypesProvider); ok {
+		return tp.HandlerTypes()
+	}
+	return getHandlerInput(handler), nil
+}
+
+// ErrorHandler TypedHandler返回error时的响应，可替换以自定义格式，返回nil时不写响应
+var ErrorHandler = func(ctx *Context, err error) interface{} {
+	return ErrorResp(err)
+}
+
+var errorStatus struct {
+	sync.RWMutex
+	targets []error
+	codes   []int
+}
+
+// RegisterErrorStatus 注册错误对应的状态码，按errors.Is匹配，先注册的优先，如
+//
+//	macaron.RegisterErrorStatus(mongo.ErrNoDocuments, http.StatusNotFound)
+func RegisterErrorStatus(target error, code int) {
+	errorStatus.Lock()
+	defer errorStatus.Unlock()
+	errorStatus.targets = append(errorStatus.targets, target)
+	errorStatus.codes = append(errorStatus.codes, code)
+}
+
+func init() {
+	RegisterErrorStatus(fs.ErrNotExist, http.StatusNotFound)
+	RegisterErrorStatus(fs.ErrPermission, http.StatusForbidden)
+	RegisterErrorStatus(context.DeadlineExceeded, http.StatusGatewayTimeout)
+}
+
+// ErrorResp 把err转换为*Error：*Error原样返回，binding.Errors返回对应状态码并带上字段详情，
+// 其他错误按RegisterErrorStatus注册的状态码，未注册的返回500
+func ErrorResp(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	var errs binding.Errors
+	if errors.As(err, &errs) {
+		e = NewError("error", errs.Error(), errs.StatusCode())
+		e.Details = errs
+		return e
+	}
+
+	code := http.StatusInternalServerError
+	errorStatus.RLock()
+	for i, target := range errorStatus.targets {
+		if errors.Is(err, target) {
+			code = errorStatus.codes[i]
+			break
+		}
+	}
+	errorStatus.RUnlock()
+	return NewError("error", err.Error(), code)
+}
@@ -0,0 +1,117 @@
+package macaron
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hudangwei/common/binding"
+)
+
+type typedInput struct {
+	Name string
+}
+
+type typedOutput struct {
+	Greeting string
+}
+
+func TestTyped(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	errTeapot := errors.New("teapot")
+	RegisterErrorStatus(errTeapot, http.StatusTeapot)
+
+	handler := Typed(func(ctx *Context, in *typedInput) (*typedOutput, error) {
+		switch in.Name {
+		case "":
+			return nil, nil
+		case "missing":
+			return nil, fmt.Errorf("load: %w", fs.ErrNotExist)
+		case "teapot":
+			return nil, errTeapot
+		case "invalid":
+			return nil, binding.Errors{{Field: "name", Rule: "required", Message: "is required"}}
+		case "forbidden":
+			return nil, NewError("error", "no", http.StatusForbidden)
+		case "boom":
+			return nil, errors.New("boom")
+		}
+		return &typedOutput{Greeting: "hello " + in.Name}, nil
+	})
+
+	in, out := HandlerTypes(handler)
+	if in != reflect.TypeOf(typedInput{}) || out != reflect.TypeOf(typedOutput{}) {
+		t.Fatalf("HandlerTypes = %v, %v", in, out)
+	}
+	if in, out := HandlerTypes(func(*Context, *typedInput) interface{} { return nil }); in != reflect.TypeOf(typedInput{}) || out != nil {
+		t.Fatalf("HandlerTypes = %v, %v", in, out)
+	}
+
+	cases := []struct {
+		name   string
+		status int
+		resp   interface{}
+	}{
+		{"bob", http.StatusOK, &typedOutput{Greeting: "hello bob"}},
+		{"", http.StatusNoContent, nil},
+		{"missing", http.StatusNotFound, nil},
+		{"teapot", http.StatusTeapot, nil},
+		{"invalid", http.StatusBadRequest, nil},
+		{"forbidden", http.StatusForbidden, nil},
+		{"boom", http.StatusInternalServerError, nil},
+	}
+	for _, c := range cases {
+		var got interface{}
+		m := New()
+		m.Map(ReturnHandler(func(ctx *Context, vals []reflect.Value) {
+			got = vals[0].Interface()
+			code := http.StatusOK
+			if e, ok := got.(*Error); ok {
+				code = e.Code
+			}
+			ctx.RespWriter.WriteHeader(code)
+		}))
+		m.Use(func(ctx *Context) {
+			if ctx.InputType != reflect.TypeOf(typedInput{}) {
+				t.Fatalf("InputType = %v", ctx.InputType)
+			}
+			ctx.Map(&typedInput{Name: c.name})
+		})
+
+		w := httptest.NewRecorder()
+		gctx, _ := gin.CreateTestContext(w)
+		gctx.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+		m.Wraps(handler)(gctx)
+		gctx.Writer.WriteHeaderNow()
+
+		if w.Code != c.status {
+			t.Errorf("%q: status = %d, want %d", c.name, w.Code, c.status)
+		}
+		if c.resp != nil && !reflect.DeepEqual(got, c.resp) {
+			t.Errorf("%q: resp = %#v, want %#v", c.name, got, c.resp)
+		}
+	}
+}
+
+type legacyInput struct {
+	Name string
+}
+
+type Validation struct{}
+
+func (in *legacyInput) Valid(v *Validation) {}
+
+// 输入类型实现beego的ValidFormer时注册路由即报错
+func TestWrapsLegacyValid(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Fatal("Wraps did not panic")
+		}
+	}()
+	New().Wraps(func(ctx *Context, in *legacyInput) interface{} { return OK })
+}
@@ -17,13 +17,14 @@ func (r Result) BeforeWrite() interface{} {
 }
 
 type Error struct {
-	Result  string `json:"result"`
-	Message string `json:"msg"`
-	Code    int    `json:"-"`
+	Result  string      `json:"result"`
+	Message string      `json:"msg"`
+	Code    int         `json:"-"`
+	Details interface{} `json:"details,omitempty"` // 错误详情，如参数校验失败的字段列表
 }
 
 func NewError(result, msg string, code int) *Error {
-	return &Error{result, msg, code}
+	return &Error{Result: result, Message: msg, Code: code}
 }
 
 func (e *Error) GetStatusCode() int {
@@ -45,6 +46,16 @@ func (e *Error) Msg(msg string) *Error {
 	return &err
 }
 
+// Formatted 指定响应格式，忽略请求的Accept，如 return macaron.WithFormat("application/xml", data)
+type Formatted struct {
+	ContentType string
+	Data        interface{}
+}
+
+func WithFormat(contentType string, data interface{}) *Formatted {
+	return &Formatted{ContentType: contentType, Data: data}
+}
+
 type FileResp struct {
 	Name        string
 	ContentType string
@@ -5,11 +5,12 @@ import (
 )
 
 type User struct {
-	Uid       int64  `json:"uid"`
-	UserId    string `json:"userid"`
-	UserName  string `json:"username"`
-	Avatar    string `json:"avatar"`
-	SessionId string `json:"sessionid"`
+	Uid       int64    `json:"uid"`
+	UserId    string   `json:"userid"`
+	UserName  string   `json:"username"`
+	Avatar    string   `json:"avatar"`
+	SessionId string   `json:"sessionid"`
+	Roles     []string `json:"roles"`
 }
 
 var ctxUserKey = struct{}{}
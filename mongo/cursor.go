@@ -0,0 +1,66 @@
+package mongo
+
+import (
+	"context"
+
+	"github.com/hudangwei/common/util/ctxutil"
+	"go.mongodb.org/mongo-driver/mongo"
+	moptions "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// 逐条遍历查询结果，每条记录回调fn，在fn中通过cur.Decode解码当前记录。
+// 与FindMany不同，结果集不会一次性加载到内存，适合大批量导出；
+// 遍历耗时不可预估，因此不附加默认超时，由调用方通过ctx控制。
+// batchSize大于0时设置每批从服务端拉取的记录数。fn返回错误时中止遍历并返回该错误。
+func FindEach(ctx context.Context, db *mongo.Client, dbName, collectionName string, filter, fields, sort any, cursor, size, batchSize int, fn func(cur *mongo.Cursor) error) error {
+	ctx = ctxutil.Ensure(ctx)
+	coll := db.Database(dbName).Collection(collectionName)
+
+	mopts := moptions.Find()
+	mopts.SetProjection(fields)
+	mopts.SetSort(sort)
+	if cursor > 0 {
+		mopts.SetSkip(int64(cursor))
+	}
+	if size > 0 {
+		mopts.SetLimit(int64(size))
+	}
+	if batchSize > 0 {
+		mopts.SetBatchSize(int32(batchSize))
+	}
+
+	cur, err := coll.Find(ctx, filter, mopts)
+	if err != nil {
+		return err
+	}
+	return iterate(ctx, cur, fn)
+}
+
+// 逐条遍历Pipeline查询结果，语义同FindEach。
+func PipeEach(ctx context.Context, db *mongo.Client, dbName, collectionName string, pipeline any, batchSize int, fn func(cur *mongo.Cursor) error) error {
+	ctx = ctxutil.Ensure(ctx)
+	coll := db.Database(dbName).Collection(collectionName)
+
+	mopts := moptions.Aggregate()
+	if batchSize > 0 {
+		mopts.SetBatchSize(int32(batchSize))
+	}
+
+	cur, err := coll.Aggregate(ctx, pipeline, mopts)
+	if err != nil {
+		return err
+	}
+	return iterate(ctx, cur, fn)
+}
+
+func iterate(ctx context.Context, cur *mongo.Cursor, fn func(cur *mongo.Cursor) error) error {
+	defer cur.Close(ctx)
+
+	for cur.Next(ctx) {
+		if err := fn(cur); err != nil {
+			return err
+		}
+	}
+
+	return cur.Err()
+}
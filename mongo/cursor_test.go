@@ -0,0 +1,58 @@
+package mongo
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func newCursor(t *testing.T, err error, docs ...interface{}) *mongo.Cursor {
+	cur, cerr := mongo.NewCursorFromDocuments(docs, err, nil)
+	if cerr != nil {
+		t.Fatal(cerr)
+	}
+	return cur
+}
+
+func TestIterate(t *testing.T) {
+	docs := []interface{}{bson.M{"n": 1}, bson.M{"n": 2}, bson.M{"n": 3}}
+	var got []int32
+	collect := func(cur *mongo.Cursor) error {
+		var doc struct{ N int32 }
+		if err := cur.Decode(&doc); err != nil {
+			return err
+		}
+		got = append(got, doc.N)
+		return nil
+	}
+
+	if err := iterate(context.Background(), newCursor(t, nil, docs...), collect); err != nil || len(got) != 3 || got[2] != 3 {
+		t.Fatalf("got %v, %v", got, err)
+	}
+
+	// fn返回错误时中止遍历
+	stop := errors.New("stop")
+	got = nil
+	err := iterate(context.Background(), newCursor(t, nil, docs...), func(cur *mongo.Cursor) error {
+		if err := collect(cur); err != nil {
+			return err
+		}
+		if len(got) == 2 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop || len(got) != 2 {
+		t.Fatalf("early stop: got %v, %v", got, err)
+	}
+
+	// 游标出错时返回cur.Err()
+	failed := errors.New("cursor failed")
+	got = nil
+	if err := iterate(context.Background(), newCursor(t, failed, docs...), collect); err != failed {
+		t.Fatalf("cursor error: got %v, %v", got, err)
+	}
+}
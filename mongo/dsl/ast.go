@@ -0,0 +1,188 @@
+package dsl
+
+import (
+	"strings"
+)
+
+// 语法树中的运算符
+const (
+	OpContains     = tokenContains   // 字符串模糊匹配，其他类型等值
+	OpEqual        = tokenFullEqual  // 等值
+	OpNotEqual     = tokenNotEqual   // 字符串不包含，其他类型不等
+	OpRegex        = tokenRegexEqual // 正则
+	OpGreater      = tokenGreater
+	OpGreaterEqual = tokenGreaterEqual
+	OpLess         = tokenLess
+	OpLessEqual    = tokenLessEqual
+
+	OpAnd = tokenAnd
+	OpOr  = tokenOr
+)
+
+// 字面量类型
+const (
+	ValueText   = tokenText
+	ValueNumber = tokenNumber
+	ValueBool   = tokenBool
+	ValueRegex  = tokenRegex // /pattern/flags，只能用于~=
+)
+
+// Node 语法树节点，位置均为字符(rune)偏移，End不含
+type Node interface {
+	Pos() int
+	End() int
+	// String 返回规范化的查询语句
+	String() string
+	exprNode()
+}
+
+// Value 查询中的字面量
+type Value struct {
+	Kind     string // ValueText/ValueNumber/ValueBool
+	Text     string // 去掉引号和转义后的内容
+	Flags    string // ValueRegex的flag
+	ValuePos int
+	ValueEnd int
+}
+
+// BinaryExpr X && Y / X || Y
+type BinaryExpr struct {
+	Op    string
+	OpPos int
+	X     Node
+	Y     Node
+}
+
+// NotExpr !X
+type NotExpr struct {
+	NotPos int
+	X      Node
+}
+
+// ParenExpr (X)，保留括号位置便于高亮
+type ParenExpr struct {
+	Lparen int
+	X      Node
+	Rparen int
+}
+
+// CompareExpr tag op value
+type CompareExpr struct {
+	Tag    string
+	TagPos int
+	Op     string
+	OpPos  int
+	Value  Value
+}
+
+// RangeExpr tag=[lower TO upper]
+type RangeExpr struct {
+	Tag            string
+	TagPos         int
+	Lower          Value
+	Upper          Value
+	LowerInclusive bool
+	UpperInclusive bool
+	RangeEnd       int
+}
+
+// InExpr tag in (v1, v2) / tag not in (v1, v2)
+type InExpr struct {
+	Tag    string
+	TagPos int
+	Not    bool
+	Values []Value
+	Rparen int
+}
+
+// ExistsExpr tag=* / tag!=* / exists(tag)
+type ExistsExpr struct {
+	Tag     string
+	TagPos  int
+	Exists  bool
+	ExprEnd int
+}
+
+func (v Value) Pos() int { return v.ValuePos }
+func (v Value) End() int { return v.ValueEnd }
+
+func (e *BinaryExpr) Pos() int  { return e.X.Pos() }
+func (e *BinaryExpr) End() int  { return e.Y.End() }
+func (e *NotExpr) Pos() int     { return e.NotPos }
+func (e *NotExpr) End() int     { return e.X.End() }
+func (e *ParenExpr) Pos() int   { return e.Lparen }
+func (e *ParenExpr) End() int   { return e.Rparen + 1 }
+func (e *CompareExpr) Pos() int { return e.TagPos }
+func (e *CompareExpr) End() int { return e.Value.End() }
+func (e *RangeExpr) Pos() int   { return e.TagPos }
+func (e *RangeExpr) End() int   { return e.RangeEnd }
+func (e *InExpr) Pos() int      { return e.TagPos }
+func (e *InExpr) End() int      { return e.Rparen + 1 }
+func (e *ExistsExpr) Pos() int  { return e.TagPos }
+func (e *ExistsExpr) End() int  { return e.ExprEnd }
+
+func (*BinaryExpr) exprNode()  {}
+func (*NotExpr) exprNode()     {}
+func (*ParenExpr) exprNode()   {}
+func (*CompareExpr) exprNode() {}
+func (*RangeExpr) exprNode()   {}
+func (*InExpr) exprNode()      {}
+func (*ExistsExpr) exprNode()  {}
+
+var textEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+
+func (v Value) String() string {
+	switch v.Kind {
+	case ValueText:
+		return `"` + textEscaper.Replace(v.Text) + `"`
+	case ValueRegex:
+		return "/" + strings.ReplaceAll(v.Text, "/", `\/`) + "/" + v.Flags
+	}
+	return v.Text
+}
+
+func (e *BinaryExpr) String() string {
+	return e.X.String() + " " + e.Op + " " + e.Y.String()
+}
+
+func (e *NotExpr) String() string {
+	return "!" + e.X.String()
+}
+
+func (e *ParenExpr) String() string {
+	return "(" + e.X.String() + ")"
+}
+
+func (e *CompareExpr) String() string {
+	return e.Tag + e.Op + e.Value.String()
+}
+
+func (e *RangeExpr) String() string {
+	left, right := "{", "}"
+	if e.LowerInclusive {
+		left = "["
+	}
+	if e.UpperInclusive {
+		right = "]"
+	}
+	return e.Tag + "=" + left + e.Lower.String() + " TO " + e.Upper.String() + right
+}
+
+func (e *InExpr) String() string {
+	values := make([]string, 0, len(e.Values))
+	for _, v := range e.Values {
+		values = append(values, v.String())
+	}
+	op := " in "
+	if e.Not {
+		op = " not in "
+	}
+	return e.Tag + op + "(" + strings.Join(values, ", ") + ")"
+}
+
+func (e *ExistsExpr) String() string {
+	if e.Exists {
+		return e.Tag + "=*"
+	}
+	return e.Tag + "!=*"
+}
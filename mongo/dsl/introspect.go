@@ -0,0 +1,306 @@
+package dsl
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// 补全候选的类型
+const (
+	CandidateTag      = "tag"
+	CandidateOperator = "operator"
+	CandidateValue    = "value"
+	CandidateKeyword  = "keyword"
+
+	expectLogic = "logic"
+)
+
+type Candidate struct {
+	Kind        string `json:"kind"`
+	Text        string `json:"text"` // 替换[From, To)区间的文本
+	Description string `json:"description"`
+	Example     string `json:"example,omitempty"`
+}
+
+type Completion struct {
+	From       int          `json:"from"`
+	To         int          `json:"to"`
+	Candidates []Candidate  `json:"candidates"`
+	Error      *SyntaxError `json:"error,omitempty"` // 整条语句的校验结果，为空表示合法
+}
+
+// Complete 根据光标位置(字符偏移)返回补全候选：tag名、该tag可用的运算符、可选值和逻辑运算符，
+// 并校验整条语句。
+func Complete(query string, cursor int, configs []Config) *Completion {
+	s := []rune(query)
+	if cursor < 0 || cursor > len(s) {
+		cursor = len(s)
+	}
+	c := &Completion{From: cursor, To: cursor, Candidates: []Candidate{}}
+	if _, err := compile(query, configs); err != nil {
+		c.Error = asSyntaxError(err)
+	}
+
+	// 光标处正在输入的单词
+	from, to := cursor, cursor
+	for from > 0 && isIdentRune(s[from-1]) {
+		from--
+	}
+	for to < len(s) && isIdentRune(s[to]) {
+		to++
+	}
+	tokens, err := ParseTokens(string(s[:from]))
+	if err != nil {
+		// 光标位于未闭合的字符串中，只补全可选值
+		var serr *SyntaxError
+		if !errors.As(err, &serr) || serr.Expected != `closing "` {
+			return c
+		}
+		from, to = serr.Pos, cursor
+		if tokens, err = ParseTokens(string(s[:from])); err != nil {
+			return c
+		}
+		if expect, tag := expectAt(tokens); expect == CandidateValue {
+			c.From, c.To = from, to
+			c.Candidates = filterCandidates(valueCandidates(findConfig(configs, tag)), string(s[from+1:cursor]))
+		}
+		return c
+	}
+	c.From, c.To = from, to
+	prefix := string(s[from:cursor])
+
+	var candidates []Candidate
+	expect, tag := expectAt(tokens)
+	switch expect {
+	case CandidateTag:
+		for _, config := range configs {
+			candidates = append(candidates, Candidate{Kind: CandidateTag, Text: config.TagName, Description: config.Description, Example: config.Example})
+		}
+		if tag != tokenExists {
+			candidates = append(candidates, Candidate{Kind: CandidateKeyword, Text: "exists(", Description: "字段存在"})
+		}
+	case CandidateOperator:
+		candidates = operatorCandidates(findConfig(configs, tag))
+	case CandidateValue:
+		candidates = valueCandidates(findConfig(configs, tag))
+	case CandidateKeyword:
+		candidates = []Candidate{{Kind: CandidateKeyword, Text: tag}}
+	case expectLogic:
+		candidates = []Candidate{
+			{Kind: CandidateOperator, Text: tokenAnd, Description: "并且"},
+			{Kind: CandidateOperator, Text: tokenOr, Description: "或者"},
+		}
+		if openBrackets(tokens) > 0 {
+			candidates = append(candidates, Candidate{Kind: CandidateOperator, Text: tokenRightBracket})
+		}
+	}
+	c.Candidates = filterCandidates(candidates, prefix)
+	return c
+}
+
+// 根据光标前的token判断期望的输入，返回候选类型和相关的tag(或关键字)
+func expectAt(tokens []Token) (string, string) {
+	if len(tokens) == 0 {
+		return CandidateTag, ""
+	}
+	last := tokens[len(tokens)-1]
+	switch last.name {
+	case tokenAnd, tokenOr, tokenNot:
+		return CandidateTag, ""
+	case tokenLeftBracket:
+		if len(tokens) >= 2 && tokens[len(tokens)-2].name == tokenTag {
+			keyword := strings.ToLower(tokens[len(tokens)-2].content)
+			if keyword == tokenExists {
+				return CandidateTag, tokenExists
+			}
+			if keyword == tokenIn {
+				return CandidateValue, lastTag(tokens)
+			}
+		}
+		return CandidateTag, ""
+	case tokenComma, tokenRangeStart:
+		return CandidateValue, lastTag(tokens)
+	case tokenContains, tokenFullEqual, tokenNotEqual, tokenRegexEqual, tokenGreater, tokenGreaterEqual, tokenLess, tokenLessEqual:
+		return CandidateValue, lastTag(tokens)
+	case tokenTag:
+		switch {
+		case strings.EqualFold(last.content, "not") && len(tokens) >= 2:
+			return CandidateKeyword, tokenIn
+		case strings.EqualFold(last.content, tokenIn) && len(tokens) >= 2:
+			return CandidateKeyword, tokenLeftBracket
+		case strings.EqualFold(last.content, tokenRangeTo) && len(tokens) >= 2:
+			return CandidateValue, lastTag(tokens)
+		}
+		return CandidateOperator, last.content
+	case tokenText, tokenNumber, tokenBool:
+		if len(tokens) >= 2 {
+			switch prev := tokens[len(tokens)-2]; {
+			case prev.name == tokenRangeStart:
+				return CandidateKeyword, tokenRangeTo
+			case prev.name == tokenTag && strings.EqualFold(prev.content, tokenRangeTo):
+				return CandidateKeyword, "]"
+			}
+		}
+	}
+	return expectLogic, ""
+}
+
+// 光标前最近的tag名，跳过关键字
+func lastTag(tokens []Token) string {
+	for i := len(tokens) - 1; i >= 0; i-- {
+		if tokens[i].name != tokenTag {
+			continue
+		}
+		switch strings.ToLower(tokens[i].content) {
+		case tokenIn, "not", strings.ToLower(tokenRangeTo), tokenExists:
+			continue
+		}
+		return tokens[i].content
+	}
+	return ""
+}
+
+func openBrackets(tokens []Token) int {
+	n := 0
+	for _, token := range tokens {
+		switch token.name {
+		case tokenLeftBracket:
+			n++
+		case tokenRightBracket:
+			n--
+		}
+	}
+	return n
+}
+
+func operatorCandidates(config *Config) []Candidate {
+	if config == nil {
+		return nil
+	}
+	candidates := []Candidate{
+		{Kind: CandidateOperator, Text: tokenFullEqual, Description: "等于"},
+	}
+	switch config.Type {
+	case ConfigTypeString:
+		candidates = append(candidates,
+			Candidate{Kind: CandidateOperator, Text: tokenContains, Description: "包含，不区分大小写"},
+			Candidate{Kind: CandidateOperator, Text: tokenNotEqual, Description: "不包含"},
+		)
+		if config.Regex {
+			candidates = append(candidates, Candidate{Kind: CandidateOperator, Text: tokenRegexEqual, Description: "正则匹配", Example: config.TagName + `~=/pattern/i`})
+		}
+	case ConfigTypeBool:
+		return append(candidates, Candidate{Kind: CandidateOperator, Text: tokenNotEqual, Description: "不等于"})
+	default:
+		candidates = append(candidates, Candidate{Kind: CandidateOperator, Text: tokenNotEqual, Description: "不等于"})
+	}
+	candidates = append(candidates,
+		Candidate{Kind: CandidateOperator, Text: tokenGreater, Description: "大于"},
+		Candidate{Kind: CandidateOperator, Text: tokenGreaterEqual, Description: "大于等于"},
+		Candidate{Kind: CandidateOperator, Text: tokenLess, Description: "小于"},
+		Candidate{Kind: CandidateOperator, Text: tokenLessEqual, Description: "小于等于"},
+		Candidate{Kind: CandidateOperator, Text: "=[", Description: "区间", Example: config.TagName + "=[a TO b]"},
+		Candidate{Kind: CandidateOperator, Text: tokenIn, Description: "属于", Example: config.TagName + " in (a, b)"},
+		Candidate{Kind: CandidateOperator, Text: tokenNotIn, Description: "不属于", Example: config.TagName + " not in (a, b)"},
+		Candidate{Kind: CandidateOperator, Text: "=*", Description: "字段存在"},
+	)
+	return candidates
+}
+
+func valueCandidates(config *Config) []Candidate {
+	if config == nil {
+		return nil
+	}
+	if config.Type == ConfigTypeBool {
+		return []Candidate{{Kind: CandidateValue, Text: "true"}, {Kind: CandidateValue, Text: "false"}}
+	}
+	var candidates []Candidate
+	for _, v := range config.Enum {
+		text := v
+		if config.Type != ConfigTypeNumber {
+			text = Value{Kind: ValueText, Text: v}.String()
+		}
+		candidates = append(candidates, Candidate{Kind: CandidateValue, Text: text})
+	}
+	return candidates
+}
+
+// 按已输入的前缀过滤，不区分大小写，字符串候选忽略引号
+func filterCandidates(candidates []Candidate, prefix string) []Candidate {
+	ret := []Candidate{}
+	prefix = strings.ToLower(prefix)
+	for _, c := range candidates {
+		if strings.HasPrefix(strings.ToLower(strings.TrimPrefix(c.Text, `"`)), prefix) {
+			ret = append(ret, c)
+		}
+	}
+	return ret
+}
+
+func asSyntaxError(err error) *SyntaxError {
+	var serr *SyntaxError
+	if errors.As(err, &serr) {
+		return serr
+	}
+	return newSyntaxError(0, "valid query", err.Error())
+}
+
+func compile(query string, configs []Config) (*Rule, error) {
+	rule, err := Parse(query)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := rule.ToMongo(configs); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+type SQLWhere struct {
+	Where string        `json:"where"`
+	Args  []interface{} `json:"args"`
+}
+
+type Explanation struct {
+	Query          string          `json:"query"`           // 规范化后的查询语句
+	Mongo          json.RawMessage `json:"mongo"`           // Mongo Extended JSON
+	MongoOptimized json.RawMessage `json:"mongo_optimized"` // 经过Optimize的过滤条件
+	MySQL          *SQLWhere       `json:"mysql"`
+	ClickHouse     *SQLWhere       `json:"clickhouse"`
+}
+
+// Explain 返回规范化的查询语句以及生成的Mongo/SQL过滤条件，用于调试
+func Explain(query string, configs []Config) (*Explanation, error) {
+	rule, err := Parse(query)
+	if err != nil {
+		return nil, err
+	}
+	filter, err := rule.ToMongo(configs)
+	if err != nil {
+		return nil, err
+	}
+	mongo, err := bson.MarshalExtJSON(filter, false, false)
+	if err != nil {
+		return nil, err
+	}
+	optimized, err := bson.MarshalExtJSON(Optimize(filter), false, false)
+	if err != nil {
+		return nil, err
+	}
+	ret := &Explanation{Query: rule.String(), Mongo: mongo, MongoOptimized: optimized}
+	for _, dialect := range []Dialect{DialectMySQL, DialectClickHouse} {
+		where, args, err := rule.ToSQL(configs, dialect)
+		if err != nil {
+			return nil, err
+		}
+		if dialect == DialectMySQL {
+			ret.MySQL = &SQLWhere{Where: where, Args: args}
+		} else {
+			ret.ClickHouse = &SQLWhere{Where: where, Args: args}
+		}
+	}
+	return ret, nil
+}
@@ -0,0 +1,86 @@
+package dsl
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func candidateTexts(c *Completion) []string {
+	texts := []string{}
+	for _, candidate := range c.Candidates {
+		texts = append(texts, candidate.Text)
+	}
+	return texts
+}
+
+func TestComplete(t *testing.T) {
+	configs := []Config{
+		{TagName: "scheme", Type: ConfigTypeString, Enum: []string{"http", "https"}},
+		{TagName: "server", Type: ConfigTypeString, Regex: true},
+		{TagName: "status_code", Type: ConfigTypeNumber, Enum: []string{"200", "404"}},
+		{TagName: "is_req", Type: ConfigTypeBool},
+	}
+	cases := []struct {
+		query    string
+		cursor   int
+		from, to int
+		want     []string
+	}{
+		{"", 0, 0, 0, []string{"scheme", "server", "status_code", "is_req", "exists("}},
+		{"s", 1, 0, 1, []string{"scheme", "server", "status_code"}},
+		{"se && is_req==true", 1, 0, 2, []string{"scheme", "server", "status_code"}},
+		{"se && is_req==true", 2, 0, 2, []string{"server"}},
+		{"is_req", 6, 0, 6, []string{"is_req"}},
+		{"is_req ", 7, 7, 7, []string{"==", "!="}},
+		{"server ", 7, 7, 7, []string{"==", "=", "!=", "~=", ">", ">=", "<", "<=", "=[", "in", "not in", "=*"}},
+		{"status_code n", 13, 12, 13, []string{"not in"}},
+		{"status_code not ", 16, 16, 16, []string{"in"}},
+		{"status_code==", 13, 13, 13, []string{"200", "404"}},
+		{"status_code in (200, 4", 22, 21, 22, []string{"404"}},
+		{"scheme=\"ht", 10, 7, 10, []string{"\"http\"", "\"https\""}},
+		{"scheme=\"https", 13, 7, 13, []string{"\"https\""}},
+		{"is_req=", 7, 7, 7, []string{"true", "false"}},
+		{"status_code=[200 ", 17, 17, 17, []string{"TO"}},
+		{"(is_req==true ", 14, 14, 14, []string{"&&", "||", ")"}},
+		{"is_req==true && exists(", 23, 23, 23, []string{"scheme", "server", "status_code", "is_req"}},
+	}
+	for _, c := range cases {
+		got := Complete(c.query, c.cursor, configs)
+		if got.From != c.from || got.To != c.to || !reflect.DeepEqual(candidateTexts(got), c.want) {
+			t.Errorf("%q@%d: got [%d,%d) %v, want [%d,%d) %v", c.query, c.cursor, got.From, got.To, candidateTexts(got), c.from, c.to, c.want)
+		}
+	}
+
+	if c := Complete("is_req==true", 12, configs); c.Error != nil {
+		t.Errorf("expect valid, got %v", c.Error)
+	}
+	if c := Complete("is_req>true", 11, configs); c.Error == nil || c.Error.Pos != 6 {
+		t.Errorf("expect error at 6, got %v", c.Error)
+	}
+}
+
+func TestExplain(t *testing.T) {
+	e, err := Explain("server~=/nginx/i&&(status_code=[200 TO 299}||status_code in (404,500)) && !tag=*", webSearchConfigs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "server~=/nginx/i && (status_code=[200 TO 299} || status_code in (404, 500)) && !tag=*"; e.Query != want {
+		t.Errorf("query got %s, want %s", e.Query, want)
+	}
+	var got, want interface{}
+	json.Unmarshal(e.Mongo, &got)
+	json.Unmarshal([]byte(`{"$and":[{"$and":[{"server":{"$options":"i","$regex":"nginx"}},{"$or":[{"statuscode":{"$gte":200,"$lt":299}},{"statuscode":{"$in":[404,500]}}]}]},{"$nor":[{"tags.name":{"$exists":true}}]}]}`), &want)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("mongo got %s, want %v", e.Mongo, want)
+	}
+	if e.MySQL == nil || e.ClickHouse == nil || len(e.MySQL.Args) != 6 {
+		t.Errorf("sql got %+v %+v", e.MySQL, e.ClickHouse)
+	}
+
+	// 规范化后的语句解析结果不变
+	rule, err := Parse(e.Query)
+	if err != nil || rule.String() != e.Query {
+		t.Errorf("normalized query reparse got %v %v", rule, err)
+	}
+}
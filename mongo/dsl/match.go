@@ -0,0 +1,363 @@
+package dsl
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Match 判断record是否满足查询条件，语义与ToMongo一致。
+// record可以是map[string]any、bson.D或struct(及其指针)，ColumnName中的点号按层级取值，
+// 字段名依次匹配bson tag、json tag和不区分大小写的字段名；路径上遇到数组时与Mongo一样匹配任一元素。
+func (r *Rule) Match(configs []Config, record interface{}) (bool, error) {
+	if r == nil || r.root == nil {
+		return false, newSyntaxError(0, "expression", tokenEOF)
+	}
+	return r.match(r.root, configs, reflect.ValueOf(record))
+}
+
+func (r *Rule) match(node Node, configs []Config, record reflect.Value) (bool, error) {
+	switch n := node.(type) {
+	case *ParenExpr:
+		return r.match(n.X, configs, record)
+	case *NotExpr:
+		ok, err := r.match(n.X, configs, record)
+		return !ok && err == nil, err
+	case *BinaryExpr:
+		ok, err := r.match(n.X, configs, record)
+		if err != nil {
+			return false, err
+		}
+		// 短路求值
+		if ok == (n.Op == OpOr) {
+			return ok, nil
+		}
+		return r.match(n.Y, configs, record)
+	case *CompareExpr:
+		return r.matchCompare(n, configs, record)
+	case *RangeExpr:
+		currentConfig, column, err := lookupConfig(configs, n.Tag, n.TagPos)
+		if err != nil {
+			return false, err
+		}
+		if currentConfig.Type == ConfigTypeBool {
+			return false, newSyntaxError(n.Lower.Pos(), "comparable value, bool tag "+n.Tag+" not support range query", "range")
+		}
+		lower, err := valueOf(n.Lower, currentConfig)
+		if err != nil {
+			return false, err
+		}
+		upper, err := valueOf(n.Upper, currentConfig)
+		if err != nil {
+			return false, err
+		}
+		return anyValue(record, column, func(v reflect.Value) bool {
+			c1, ok1 := compareValue(v, lower)
+			c2, ok2 := compareValue(v, upper)
+			return ok1 && ok2 && (c1 > 0 || (c1 == 0 && n.LowerInclusive)) && (c2 < 0 || (c2 == 0 && n.UpperInclusive))
+		}), nil
+	case *InExpr:
+		currentConfig, column, err := lookupConfig(configs, n.Tag, n.TagPos)
+		if err != nil {
+			return false, err
+		}
+		values := make([]interface{}, 0, len(n.Values))
+		for _, value := range n.Values {
+			v, err := valueOf(value, currentConfig)
+			if err != nil {
+				return false, err
+			}
+			values = append(values, v)
+		}
+		found := anyValue(record, column, func(v reflect.Value) bool {
+			for _, q := range values {
+				if c, ok := compareValue(v, q); ok && c == 0 {
+					return true
+				}
+			}
+			return false
+		})
+		return found != n.Not, nil
+	case *ExistsExpr:
+		_, column, err := lookupConfig(configs, n.Tag, n.TagPos)
+		if err != nil {
+			return false, err
+		}
+		exists := anyValue(record, column, func(reflect.Value) bool { return true })
+		return exists == n.Exists, nil
+	}
+	return false, fmt.Errorf("unknown node type %T", node)
+}
+
+func (r *Rule) matchCompare(n *CompareExpr, configs []Config, record reflect.Value) (bool, error) {
+	currentConfig, column, err := lookupConfig(configs, n.Tag, n.TagPos)
+	if err != nil {
+		return false, err
+	}
+	isString := currentConfig.Type == ConfigTypeString
+
+	switch n.Op {
+	case tokenRegexEqual:
+		if err := checkRegexConfig(n, currentConfig); err != nil {
+			return false, err
+		}
+		reg, err := r.regexp(inlineRegex(regexOf(n.Value)))
+		if err != nil {
+			return false, err
+		}
+		return anyValue(record, column, func(v reflect.Value) bool {
+			s, ok := stringValue(v)
+			return ok && reg.MatchString(s)
+		}), nil
+	case tokenContains, tokenNotEqual:
+		if isString {
+			// 与ToMongo一致，小写模糊
+			reg, err := r.regexp("(?i)" + regexp.QuoteMeta(n.Value.Text))
+			if err != nil {
+				return false, err
+			}
+			found := anyValue(record, column, func(v reflect.Value) bool {
+				s, ok := stringValue(v)
+				return ok && reg.MatchString(s)
+			})
+			return found != (n.Op == tokenNotEqual), nil
+		}
+		q, err := valueOf(n.Value, currentConfig)
+		if err != nil {
+			return false, err
+		}
+		found := anyValue(record, column, func(v reflect.Value) bool {
+			c, ok := compareValue(v, q)
+			return ok && c == 0
+		})
+		return found != (n.Op == tokenNotEqual), nil
+	case tokenFullEqual:
+		q, err := valueOf(n.Value, currentConfig)
+		if err != nil {
+			return false, err
+		}
+		return anyValue(record, column, func(v reflect.Value) bool {
+			c, ok := compareValue(v, q)
+			return ok && c == 0
+		}), nil
+	case tokenGreater, tokenGreaterEqual, tokenLess, tokenLessEqual:
+		if currentConfig.Type == ConfigTypeBool {
+			return false, newSyntaxError(n.OpPos, "operator supported by bool tag "+n.Tag, n.Op)
+		}
+		q, err := valueOf(n.Value, currentConfig)
+		if err != nil {
+			return false, err
+		}
+		return anyValue(record, column, func(v reflect.Value) bool {
+			c, ok := compareValue(v, q)
+			if !ok {
+				return false
+			}
+			switch n.Op {
+			case tokenGreater:
+				return c > 0
+			case tokenGreaterEqual:
+				return c >= 0
+			case tokenLess:
+				return c < 0
+			}
+			return c <= 0
+		}), nil
+	}
+	return false, newSyntaxError(n.OpPos, "operator", n.Op)
+}
+
+// 编译后的正则按表达式缓存在Rule上
+func (r *Rule) regexp(expr string) (*regexp.Regexp, error) {
+	if v, ok := r.regexps.Load(expr); ok {
+		return v.(*regexp.Regexp), nil
+	}
+	reg, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	r.regexps.Store(expr, reg)
+	return reg, nil
+}
+
+// 是否存在满足fn的取值
+func anyValue(record reflect.Value, column string, fn func(reflect.Value) bool) bool {
+	for _, v := range lookupPath(record, strings.Split(column, "."), nil) {
+		if fn(v) {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	bsonDType = reflect.TypeOf(bson.D{})
+	bytesType = reflect.TypeOf([]byte(nil))
+	timeType  = reflect.TypeOf(time.Time{})
+	dateType  = reflect.TypeOf(primitive.DateTime(0))
+)
+
+func indirect(v reflect.Value) reflect.Value {
+	for v.IsValid() && (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// 按路径取值，路径经过数组时展开数组，与Mongo的点号路径语义一致
+func lookupPath(v reflect.Value, path []string, out []reflect.Value) []reflect.Value {
+	v = indirect(v)
+	if !v.IsValid() {
+		return out
+	}
+	isArray := (v.Kind() == reflect.Slice || v.Kind() == reflect.Array) && v.Type() != bytesType && v.Type() != bsonDType
+	if len(path) == 0 {
+		if !isArray || v.Len() == 0 {
+			return append(out, v)
+		}
+		for i := 0; i < v.Len(); i++ {
+			if elem := indirect(v.Index(i)); elem.IsValid() {
+				out = append(out, elem)
+			}
+		}
+		return out
+	}
+
+	switch {
+	case v.Type() == bsonDType:
+		for _, e := range v.Interface().(bson.D) {
+			if e.Key == path[0] {
+				return lookupPath(reflect.ValueOf(e.Value), path[1:], out)
+			}
+		}
+	case v.Kind() == reflect.Map && v.Type().Key().Kind() == reflect.String:
+		// 优先匹配包含点号的完整key
+		for i := len(path); i >= 1; i-- {
+			key := reflect.ValueOf(strings.Join(path[:i], ".")).Convert(v.Type().Key())
+			if mv := v.MapIndex(key); mv.IsValid() {
+				return lookupPath(mv, path[i:], out)
+			}
+		}
+	case v.Kind() == reflect.Struct:
+		if f, ok := structField(v, path[0]); ok {
+			return lookupPath(f, path[1:], out)
+		}
+	case isArray:
+		for i := 0; i < v.Len(); i++ {
+			out = lookupPath(v.Index(i), path, out)
+		}
+	}
+	return out
+}
+
+func structField(v reflect.Value, name string) (reflect.Value, bool) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		if tagName(field.Tag.Get("bson")) == name || tagName(field.Tag.Get("json")) == name || strings.EqualFold(field.Name, name) {
+			return v.Field(i), true
+		}
+	}
+	// 嵌入的结构体
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.Anonymous {
+			continue
+		}
+		if f := indirect(v.Field(i)); f.IsValid() && f.Kind() == reflect.Struct {
+			if ret, ok := structField(f, name); ok {
+				return ret, true
+			}
+		}
+	}
+	return reflect.Value{}, false
+}
+
+func tagName(tag string) string {
+	name, _, _ := strings.Cut(tag, ",")
+	return name
+}
+
+func stringValue(v reflect.Value) (string, bool) {
+	if v.Kind() == reflect.String {
+		return v.String(), true
+	}
+	if v.Type() == bytesType {
+		return string(v.Bytes()), true
+	}
+	return "", false
+}
+
+func floatValue(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
+
+func timeValue(v reflect.Value) (time.Time, bool) {
+	switch v.Type() {
+	case timeType:
+		return v.Interface().(time.Time), true
+	case dateType:
+		return v.Interface().(primitive.DateTime).Time(), true
+	}
+	return time.Time{}, false
+}
+
+// 比较记录中的值v与查询值q，类型不可比较时ok为false
+func compareValue(v reflect.Value, q interface{}) (c int, ok bool) {
+	switch q := q.(type) {
+	case int:
+		return compareValue(v, float64(q))
+	case float64:
+		f, ok := floatValue(v)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case f < q:
+			return -1, true
+		case f > q:
+			return 1, true
+		}
+		return 0, true
+	case string:
+		s, ok := stringValue(v)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(s, q), true
+	case bool:
+		if v.Kind() != reflect.Bool {
+			return 0, false
+		}
+		if v.Bool() != q {
+			return 1, true
+		}
+		return 0, true
+	case time.Time:
+		t, ok := timeValue(v)
+		if !ok {
+			return 0, false
+		}
+		return t.Compare(q), true
+	}
+	return 0, false
+}
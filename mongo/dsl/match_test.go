@@ -0,0 +1,90 @@
+package dsl
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+type matchTag struct {
+	Name    string `bson:"name"`
+	Content string `json:"content"`
+}
+
+type matchHost struct {
+	Country string
+}
+
+type matchRecord struct {
+	Domain     string     `json:"domain"`
+	StatusCode int64      `bson:"statuscode"`
+	Host       *matchHost `json:"host"`
+	Tags       []matchTag `json:"tags"`
+	IsReq      bool       `json:"is_req"`
+	Title      *string    `json:"title"`
+}
+
+func TestMatch(t *testing.T) {
+	record := &matchRecord{
+		Domain:     "www.Example.com",
+		StatusCode: 301,
+		Host:       &matchHost{Country: "中国"},
+		Tags:       []matchTag{{Name: "cdn", Content: "nginx"}, {Name: "waf", Content: "Cloudflare"}},
+		IsReq:      true,
+	}
+	m := map[string]interface{}{
+		"domain":       "www.Example.com",
+		"statuscode":   301,
+		"host.country": "中国",
+		"tags":         []interface{}{bson.M{"name": "cdn", "content": "nginx"}, bson.D{{Key: "name", Value: "waf"}, {Key: "content", Value: "Cloudflare"}}},
+		"is_req":       true,
+	}
+	cases := []struct {
+		dsl  string
+		want bool
+	}{
+		{"domain=\"example\"", true},
+		{"domain=\"example.org\"", false},
+		{"domain==\"www.Example.com\"", true},
+		{"domain==\"www.example.com\"", false},
+		{"domain!=\"EXAMPLE\"", false},
+		{"domain~=\"ample.c\"", true},
+		{"country=\"中\"", true},
+		{"status_code=301 && is_req==true", true},
+		{"status_code!=301 || is_req==false", false},
+		{"status_code>300 && status_code<=301", true},
+		{"status_code=[200 TO 299]", false},
+		{"status_code={300 TO 301]", true},
+		{"status_code in (200, 301)", true},
+		{"status_code not in (200, 301)", false},
+		{"tag==\"waf\" && app=\"cloudflare\"", true},
+		{"tag in (\"cdn\", \"x\")", true},
+		{"tag==\"x\"", false},
+		{"!(tag==\"x\")", true},
+		{"tag=* && exists(app)", true},
+		{"title=*", false},
+		{"title!=*", true},
+		{"server=\"nginx\"", false},
+		{"server!=\"nginx\"", true},
+	}
+	for _, c := range cases {
+		rule, err := Parse(c.dsl)
+		if err != nil {
+			t.Fatalf("%s: %v", c.dsl, err)
+		}
+		for _, r := range []interface{}{record, m} {
+			got, err := rule.Match(webSearchConfigs, r)
+			if err != nil {
+				t.Fatalf("%s: %v", c.dsl, err)
+			}
+			if got != c.want {
+				t.Errorf("%s: match %T got %v, want %v", c.dsl, r, got, c.want)
+			}
+		}
+	}
+
+	rule, _ := Parse("unknown=1")
+	if _, err := rule.Match(webSearchConfigs, m); err == nil {
+		t.Errorf("unknown tag: expect error")
+	}
+}
@@ -0,0 +1,152 @@
+package dsl
+
+import (
+	"reflect"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Optimize 优化ToMongo生成的过滤条件，不改变语义：
+//   - 展开嵌套的同类$and/$or为单个n元运算，$nor中唯一的$or直接展开
+//   - 删除重复的子条件
+//   - 同一字段的等值$or合并为$in
+//   - 只有一个子条件的$and/$or替换为子条件本身
+//
+// 子条件保持原有顺序。
+func Optimize(filter bson.D) bson.D {
+	ret := make(bson.D, 0, len(filter))
+	for _, e := range filter {
+		clauses, ok := e.Value.([]bson.D)
+		if !ok {
+			ret = append(ret, e)
+			continue
+		}
+		switch e.Key {
+		case "$and", "$or":
+			clauses = dedupe(flatten(e.Key, clauses))
+			if e.Key == "$or" {
+				clauses = mergeIn(clauses)
+			}
+			if len(clauses) == 1 && len(filter) == 1 {
+				return clauses[0]
+			}
+			ret = append(ret, bson.E{Key: e.Key, Value: clauses})
+		case "$nor":
+			// !(a || b) 等价于 $nor: [a, b]
+			clauses = dedupe(flatten("$or", clauses))
+			ret = append(ret, bson.E{Key: e.Key, Value: clauses})
+		default:
+			ret = append(ret, e)
+		}
+	}
+	return ret
+}
+
+func optimizeAll(clauses []bson.D) []bson.D {
+	ret := make([]bson.D, 0, len(clauses))
+	for _, clause := range clauses {
+		ret = append(ret, Optimize(clause))
+	}
+	return ret
+}
+
+// 优化子条件，并把与op相同的子运算展开到当前层
+func flatten(op string, clauses []bson.D) []bson.D {
+	ret := make([]bson.D, 0, len(clauses))
+	for _, clause := range optimizeAll(clauses) {
+		if len(clause) == 1 && clause[0].Key == op {
+			if sub, ok := clause[0].Value.([]bson.D); ok {
+				ret = append(ret, sub...)
+				continue
+			}
+		}
+		ret = append(ret, clause)
+	}
+	return ret
+}
+
+func dedupe(clauses []bson.D) []bson.D {
+	ret := make([]bson.D, 0, len(clauses))
+	for _, clause := range clauses {
+		duplicate := false
+		for _, exist := range ret {
+			if reflect.DeepEqual(clause, exist) {
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			ret = append(ret, clause)
+		}
+	}
+	return ret
+}
+
+// 等值子条件的字段和取值：{f: v}、{f: {$eq: v}}、{f: {$in: [...]}}
+func equalValues(clause bson.D) (string, []interface{}, bool) {
+	if len(clause) != 1 || len(clause[0].Key) == 0 || clause[0].Key[0] == '$' {
+		return "", nil, false
+	}
+	field := clause[0].Key
+	switch v := clause[0].Value.(type) {
+	case bson.M:
+		if len(v) != 1 {
+			return "", nil, false
+		}
+		if eq, ok := v["$eq"]; ok {
+			return field, []interface{}{eq}, true
+		}
+		if in, ok := v["$in"].([]interface{}); ok {
+			return field, in, true
+		}
+		return "", nil, false
+	case bson.D, bson.A, []interface{}, []bson.D, nil:
+		return "", nil, false
+	}
+	return field, []interface{}{clause[0].Value}, true
+}
+
+// 合并同一字段的等值条件为$in，合并后的条件位于该字段首次出现的位置
+func mergeIn(clauses []bson.D) []bson.D {
+	type group struct {
+		index  int
+		values []interface{}
+		count  int
+	}
+	groups := map[string]*group{}
+	var ret []bson.D
+	for _, clause := range clauses {
+		field, values, ok := equalValues(clause)
+		if !ok {
+			ret = append(ret, clause)
+			continue
+		}
+		g, exist := groups[field]
+		if !exist {
+			g = &group{index: len(ret)}
+			groups[field] = g
+			ret = append(ret, clause)
+		}
+		g.count++
+		for _, v := range values {
+			if !containsValue(g.values, v) {
+				g.values = append(g.values, v)
+			}
+		}
+	}
+	for field, g := range groups {
+		if g.count > 1 {
+			ret[g.index] = bson.D{{Key: field, Value: bson.M{"$in": g.values}}}
+		}
+	}
+	return ret
+}
+
+func containsValue(values []interface{}, v interface{}) bool {
+	for _, exist := range values {
+		if reflect.DeepEqual(exist, v) {
+			return true
+		}
+	}
+	return false
+}
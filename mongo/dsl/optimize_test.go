@@ -0,0 +1,112 @@
+package dsl
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+var updateGolden = flag.Bool("update", false, "update golden files")
+
+type optimizeGolden struct {
+	Query  string          `json:"query"`
+	Before json.RawMessage `json:"before"`
+	After  json.RawMessage `json:"after"`
+}
+
+var optimizeQueries = []string{
+	"status_code==1 && status_code==2 && status_code==3 && status_code==4",
+	"status_code==1 || status_code==2 || (status_code==3 || status_code==4)",
+	"status_code==200 || status_code==301 || status_code in (301, 302) || is_req==true",
+	"scheme==\"http\" || status_code=200 || scheme==\"https\" || status_code==404",
+	"domain=\"a\" && domain=\"a\" && (domain=\"b\" || domain=\"b\")",
+	"!(status_code==1 || status_code==2 || status_code==3)",
+	"(domain=\"a\" || server=\"b\") && (status_code==1 || (status_code==2 && is_req==true))",
+	"status_code==1",
+	"tag=\"x\" || tag=\"y\"",
+}
+
+func canonicalJSON(t *testing.T, raw []byte) interface{} {
+	var v interface{}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestOptimize(t *testing.T) {
+	const golden = "testdata/optimize.golden"
+	var results []optimizeGolden
+	for _, query := range optimizeQueries {
+		filter, err := Dsl2Mongo(query, webSearchConfigs)
+		if err != nil {
+			t.Fatalf("%s: %v", query, err)
+		}
+		before, err := bson.MarshalExtJSON(filter, false, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		after, err := bson.MarshalExtJSON(Optimize(filter), false, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		results = append(results, optimizeGolden{Query: query, Before: before, After: after})
+	}
+
+	if *updateGolden {
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(results); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(golden, buf.Bytes(), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	bs, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var expects []optimizeGolden
+	if err := json.Unmarshal(bs, &expects); err != nil {
+		t.Fatal(err)
+	}
+	if len(expects) != len(results) {
+		t.Fatalf("golden has %d cases, want %d, run go test -update", len(expects), len(results))
+	}
+	for i, got := range results {
+		want := expects[i]
+		if got.Query != want.Query {
+			t.Fatalf("golden case %d is %s, want %s, run go test -update", i, want.Query, got.Query)
+		}
+		if !reflect.DeepEqual(canonicalJSON(t, got.Before), canonicalJSON(t, want.Before)) {
+			t.Errorf("%s: before got %s, want %s", got.Query, got.Before, want.Before)
+		}
+		if !reflect.DeepEqual(canonicalJSON(t, got.After), canonicalJSON(t, want.After)) {
+			t.Errorf("%s: after got %s, want %s", got.Query, got.After, want.After)
+		}
+	}
+}
+
+// 合并$in后保持字段首次出现的位置和取值顺序
+func TestOptimizeKeepsOperandOrder(t *testing.T) {
+	filter, err := Dsl2Mongo("domain==\"c\" || domain==\"a\" || server==\"x\" || domain==\"b\"", webSearchConfigs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := bson.D{{Key: "$or", Value: []bson.D{
+		{{Key: "domain", Value: bson.M{"$in": []interface{}{"c", "a", "b"}}}},
+		{{Key: "server", Value: bson.M{"$eq": "x"}}},
+	}}}
+	if got := Optimize(filter); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
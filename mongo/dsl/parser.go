@@ -0,0 +1,256 @@
+package dsl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SyntaxError 查询语句错误，Pos为出错位置的字符(rune)偏移，可用于前端高亮
+type SyntaxError struct {
+	Pos      int    `json:"pos"`
+	Expected string `json:"expected"`
+	Got      string `json:"got"`
+}
+
+func newSyntaxError(pos int, expected, got string) *SyntaxError {
+	return &SyntaxError{Pos: pos, Expected: expected, Got: got}
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("syntax error at position %d: expected %s, got %s", e.Pos, e.Expected, e.Got)
+}
+
+// 递归下降解析，优先级 ! > && > ||
+//
+//	expr    = and { "||" and }
+//	and     = unary { "&&" unary }
+//	unary   = "!" unary | primary
+//	primary = "(" expr ")" | "exists" "(" tag ")" | tag cond
+//	cond    = op value | "~=" regex | ("=" | "!=") "*" | "=" range | ["not"] "in" "(" value { "," value } ")"
+//	range   = ("[" | "{") value "TO" value ("]" | "}")
+type parser struct {
+	stream *tokenStream
+	depth  int
+}
+
+// MaxNesting 解析时允许的最大嵌套层数(括号和!)，防止恶意语句耗尽栈空间
+var MaxNesting = 100
+
+func parse(tokens []Token) (Node, error) {
+	p := &parser{stream: newTokenStream(tokens)}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.stream.peek(); tok.name != tokenEOF {
+		return nil, p.unexpected(tok, "&& or ||")
+	}
+	return node, nil
+}
+
+func (p *parser) unexpected(tok Token, expected string) *SyntaxError {
+	return newSyntaxError(tok.pos, expected, tok.display())
+}
+
+func (p *parser) expect(name, expected string) (Token, error) {
+	tok := p.stream.next()
+	if tok.name != name {
+		return tok, p.unexpected(tok, expected)
+	}
+	return tok, nil
+}
+
+func (p *parser) parseOr() (Node, error) {
+	x, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.stream.peek().name == tokenOr {
+		op := p.stream.next()
+		y, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		x = &BinaryExpr{Op: OpOr, OpPos: op.pos, X: x, Y: y}
+	}
+	return x, nil
+}
+
+func (p *parser) parseAnd() (Node, error) {
+	x, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.stream.peek().name == tokenAnd {
+		op := p.stream.next()
+		y, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		x = &BinaryExpr{Op: OpAnd, OpPos: op.pos, X: x, Y: y}
+	}
+	return x, nil
+}
+
+func (p *parser) parseUnary() (Node, error) {
+	p.depth++
+	defer func() { p.depth-- }()
+	if p.depth > MaxNesting {
+		tok := p.stream.peek()
+		return nil, newSyntaxError(tok.pos, fmt.Sprintf("at most %d levels of nesting", MaxNesting), tok.display())
+	}
+	if p.stream.peek().name == tokenNot {
+		not := p.stream.next()
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &NotExpr{NotPos: not.pos, X: x}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (Node, error) {
+	tok := p.stream.next()
+	switch tok.name {
+	case tokenLeftBracket:
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		right, err := p.expect(tokenRightBracket, ")")
+		if err != nil {
+			return nil, err
+		}
+		return &ParenExpr{Lparen: tok.pos, X: x, Rparen: right.pos}, nil
+	case tokenTag:
+		if strings.EqualFold(tok.content, tokenExists) && p.stream.peek().name == tokenLeftBracket {
+			return p.parseExists(tok)
+		}
+		return p.parseCond(tok)
+	}
+	return nil, p.unexpected(tok, "tag name, ( or !")
+}
+
+// exists(tag)
+func (p *parser) parseExists(keyword Token) (Node, error) {
+	p.stream.next()
+	tag, err := p.expect(tokenTag, "tag name")
+	if err != nil {
+		return nil, err
+	}
+	right, err := p.expect(tokenRightBracket, ")")
+	if err != nil {
+		return nil, err
+	}
+	return &ExistsExpr{Tag: tag.content, TagPos: keyword.pos, Exists: true, ExprEnd: right.end}, nil
+}
+
+func (p *parser) parseCond(tag Token) (Node, error) {
+	op := p.stream.next()
+	switch op.name {
+	case tokenContains, tokenNotEqual:
+		next := p.stream.peek()
+		if next.name == tokenStar {
+			p.stream.next()
+			return &ExistsExpr{Tag: tag.content, TagPos: tag.pos, Exists: op.name == tokenContains, ExprEnd: next.end}, nil
+		}
+		if next.name == tokenRangeStart && op.name == tokenContains {
+			return p.parseRange(tag)
+		}
+	case tokenRegexEqual:
+		if next := p.stream.peek(); next.name == tokenRegex {
+			p.stream.next()
+			v := Value{Kind: ValueRegex, Text: next.content, Flags: next.flags, ValuePos: next.pos, ValueEnd: next.end}
+			return &CompareExpr{Tag: tag.content, TagPos: tag.pos, Op: op.name, OpPos: op.pos, Value: v}, nil
+		}
+	case tokenFullEqual, tokenGreater, tokenGreaterEqual, tokenLess, tokenLessEqual:
+	case tokenTag:
+		if strings.EqualFold(op.content, tokenIn) {
+			return p.parseIn(tag, false)
+		}
+		if strings.EqualFold(op.content, "not") {
+			if _, err := p.expectKeyword(tokenIn); err != nil {
+				return nil, err
+			}
+			return p.parseIn(tag, true)
+		}
+		return nil, p.unexpected(op, "operator")
+	default:
+		return nil, p.unexpected(op, "operator")
+	}
+	v, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	return &CompareExpr{Tag: tag.content, TagPos: tag.pos, Op: op.name, OpPos: op.pos, Value: v}, nil
+}
+
+func (p *parser) expectKeyword(keyword string) (Token, error) {
+	tok := p.stream.next()
+	if tok.name != tokenTag || !strings.EqualFold(tok.content, keyword) {
+		return tok, p.unexpected(tok, keyword)
+	}
+	return tok, nil
+}
+
+func (p *parser) parseValue() (Value, error) {
+	tok := p.stream.next()
+	switch tok.name {
+	case tokenText, tokenNumber, tokenBool:
+		return Value{Kind: tok.name, Text: tok.content, ValuePos: tok.pos, ValueEnd: tok.end}, nil
+	}
+	return Value{}, p.unexpected(tok, "value")
+}
+
+// [lower TO upper]
+func (p *parser) parseRange(tag Token) (Node, error) {
+	start := p.stream.next()
+	lower, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := p.expectKeyword(tokenRangeTo); err != nil {
+		return nil, err
+	}
+	upper, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	end, err := p.expect(tokenRangeEnd, "] or }")
+	if err != nil {
+		return nil, err
+	}
+	return &RangeExpr{
+		Tag:            tag.content,
+		TagPos:         tag.pos,
+		Lower:          lower,
+		Upper:          upper,
+		LowerInclusive: start.content == "[",
+		UpperInclusive: end.content == "]",
+		RangeEnd:       end.end,
+	}, nil
+}
+
+// (v1, v2)
+func (p *parser) parseIn(tag Token, not bool) (Node, error) {
+	if _, err := p.expect(tokenLeftBracket, "("); err != nil {
+		return nil, err
+	}
+	in := &InExpr{Tag: tag.content, TagPos: tag.pos, Not: not}
+	for {
+		v, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		in.Values = append(in.Values, v)
+		sep := p.stream.next()
+		if sep.name == tokenRightBracket {
+			in.Rparen = sep.pos
+			return in, nil
+		}
+		if sep.name != tokenComma {
+			return nil, p.unexpected(sep, ", or )")
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package dsl
+
+import (
+	"fmt"
+)
+
+// PolicyError.Rule的取值
+const (
+	PolicyPermission  = "permission"
+	PolicyMaxDepth    = "max_depth"
+	PolicyMaxClauses  = "max_clauses"
+	PolicyMaxRegex    = "max_regex"
+	PolicyMaxInValues = "max_in_values"
+)
+
+// Policy 对外开放查询时的字段权限和代价限制，限制项为0时不限制，MaxRegex小于0时禁止正则查询
+type Policy struct {
+	Roles       []string                  // 调用方拥有的角色，与Config.Roles有交集时允许查询该字段
+	Allow       func(config *Config) bool // 自定义字段权限判断，设置后忽略Roles
+	MaxDepth    int                       // 布尔表达式最大嵌套深度，a=1为1，a=1&&b=2为2，连续相同的&&或||算一层
+	MaxClauses  int                       // 最多条件个数
+	MaxRegex    int                       // 最多~=正则条件个数
+	MaxInValues int                       // 单个in/not in最多值个数
+}
+
+// PolicyError 查询违反Policy，Pos为出错位置的字符(rune)偏移
+type PolicyError struct {
+	Pos   int    `json:"pos"`
+	Rule  string `json:"rule"`
+	Tag   string `json:"tag,omitempty"`
+	Limit int    `json:"limit,omitempty"`
+}
+
+func (e *PolicyError) Error() string {
+	switch e.Rule {
+	case PolicyPermission:
+		return fmt.Sprintf("permission denied at position %d: tag %s is not allowed", e.Pos, e.Tag)
+	case PolicyMaxDepth:
+		return fmt.Sprintf("query too complex at position %d: nesting depth exceeds %d", e.Pos, e.Limit)
+	case PolicyMaxClauses:
+		return fmt.Sprintf("query too complex at position %d: more than %d conditions", e.Pos, e.Limit)
+	case PolicyMaxRegex:
+		if e.Limit < 0 {
+			return fmt.Sprintf("regex not allowed at position %d", e.Pos)
+		}
+		return fmt.Sprintf("query too complex at position %d: more than %d regex conditions", e.Pos, e.Limit)
+	case PolicyMaxInValues:
+		return fmt.Sprintf("query too complex at position %d: more than %d values in list", e.Pos, e.Limit)
+	}
+	return fmt.Sprintf("policy %s violated at position %d", e.Rule, e.Pos)
+}
+
+// Allowed 调用方是否可以查询该字段
+func (p *Policy) Allowed(config *Config) bool {
+	if p.Allow != nil {
+		return p.Allow(config)
+	}
+	if len(config.Roles) == 0 {
+		return true
+	}
+	for _, role := range config.Roles {
+		for _, r := range p.Roles {
+			if role == r {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// FilterConfigs 返回调用方可以查询的字段配置，可用于自动补全时隐藏无权限的字段
+func FilterConfigs(configs []Config, policy *Policy) []Config {
+	if policy == nil {
+		return configs
+	}
+	allowed := make([]Config, 0, len(configs))
+	for i := range configs {
+		if policy.Allowed(&configs[i]) {
+			allowed = append(allowed, configs[i])
+		}
+	}
+	return allowed
+}
+
+// Check 检查查询是否符合Policy，应在ToMongo/ToSQL之前调用；未知字段返回*SyntaxError，其余返回*PolicyError
+func (r *Rule) Check(configs []Config, policy *Policy) error {
+	if policy == nil {
+		return nil
+	}
+	c := &policyChecker{configs: configs, policy: policy}
+	return c.check(r.root, 0, "")
+}
+
+type policyChecker struct {
+	configs []Config
+	policy  *Policy
+	clauses int
+	regexps int
+}
+
+func exceeded(limit, n int) bool {
+	return limit < 0 || (limit > 0 && n > limit)
+}
+
+// depth为外层布尔表达式的层数，parentOp为直接外层的操作符
+func (c *policyChecker) check(node Node, depth int, parentOp string) error {
+	switch n := node.(type) {
+	case *ParenExpr:
+		return c.check(n.X, depth, parentOp)
+	case *BinaryExpr:
+		if n.Op != parentOp {
+			depth++
+		}
+		if err := c.check(n.X, depth, n.Op); err != nil {
+			return err
+		}
+		return c.check(n.Y, depth, n.Op)
+	case *NotExpr:
+		return c.check(n.X, depth+1, "!")
+	case *CompareExpr:
+		if err := c.leaf(n.Tag, n.TagPos, depth); err != nil {
+			return err
+		}
+		if n.Op == OpRegex {
+			c.regexps++
+			if exceeded(c.policy.MaxRegex, c.regexps) {
+				return &PolicyError{Pos: n.OpPos, Rule: PolicyMaxRegex, Limit: c.policy.MaxRegex}
+			}
+		}
+		return nil
+	case *RangeExpr:
+		return c.leaf(n.Tag, n.TagPos, depth)
+	case *InExpr:
+		if err := c.leaf(n.Tag, n.TagPos, depth); err != nil {
+			return err
+		}
+		if limit := c.policy.MaxInValues; limit > 0 && len(n.Values) > limit {
+			return &PolicyError{Pos: n.Values[limit].ValuePos, Rule: PolicyMaxInValues, Limit: limit}
+		}
+		return nil
+	case *ExistsExpr:
+		return c.leaf(n.Tag, n.TagPos, depth)
+	}
+	return fmt.Errorf("unsupported node %T", node)
+}
+
+func (c *policyChecker) leaf(tag string, pos, depth int) error {
+	config, _, err := lookupConfig(c.configs, tag, pos)
+	if err != nil {
+		return err
+	}
+	if !c.policy.Allowed(config) {
+		return &PolicyError{Pos: pos, Rule: PolicyPermission, Tag: tag}
+	}
+	if limit := c.policy.MaxDepth; limit > 0 && depth+1 > limit {
+		return &PolicyError{Pos: pos, Rule: PolicyMaxDepth, Limit: limit}
+	}
+	c.clauses++
+	if limit := c.policy.MaxClauses; limit > 0 && c.clauses > limit {
+		return &PolicyError{Pos: pos, Rule: PolicyMaxClauses, Limit: limit}
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package dsl
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPolicy(t *testing.T) {
+	configs := append([]Config{
+		{
+			TagName:    "owner",
+			ColumnName: "owner",
+			Type:       ConfigTypeString,
+			Roles:      []string{"admin", "auditor"},
+		},
+	}, webSearchConfigs...)
+	for _, tag := range []string{"a", "b", "c", "d", "e"} {
+		configs = append(configs, Config{TagName: tag, ColumnName: tag, Type: ConfigTypeNumber})
+	}
+	limits := Policy{MaxDepth: 4, MaxClauses: 4, MaxRegex: 1, MaxInValues: 2}
+
+	cases := []struct {
+		dsl   string
+		roles []string
+		rule  string
+		pos   int
+	}{
+		{"domain=\"a.com\"", nil, "", 0},
+		{"owner=\"bob\"", []string{"admin"}, "", 0},
+		{"domain=\"a.com\" && owner=\"bob\"", []string{"guest"}, PolicyPermission, 18},
+		{"exists(owner)", nil, PolicyPermission, 0},
+		{"a=1 && b=2 && c=3 && d=4", nil, "", 0},
+		{"a=1 && b=2 && c=3 && d=4 && e=5", nil, PolicyMaxClauses, 28},
+		{"a=1 && (b=2 || (c=3 && d=4))", nil, "", 0},
+		{"a=1 && (b=2 || !(c=3 && d=4))", nil, PolicyMaxDepth, 17},
+		{"domain~=/a/ || server~=/b/", nil, PolicyMaxRegex, 21},
+		{"domain in (\"a\", \"b\", \"c\")", nil, PolicyMaxInValues, 21},
+	}
+	for _, c := range cases {
+		rule, err := Parse(c.dsl)
+		if err != nil {
+			t.Fatalf("%s: %v", c.dsl, err)
+		}
+		policy := limits
+		policy.Roles = c.roles
+		err = rule.Check(configs, &policy)
+		if c.rule == "" {
+			if err != nil {
+				t.Errorf("%s: %v", c.dsl, err)
+			}
+			continue
+		}
+		var pe *PolicyError
+		if !errors.As(err, &pe) {
+			t.Errorf("%s: got %v, want PolicyError", c.dsl, err)
+			continue
+		}
+		if pe.Rule != c.rule || pe.Pos != c.pos {
+			t.Errorf("%s: got %s at %d, want %s at %d", c.dsl, pe.Rule, pe.Pos, c.rule, c.pos)
+		}
+	}
+
+	rule, _ := Parse("domain~=/a/")
+	err := rule.Check(configs, &Policy{MaxRegex: -1})
+	if err == nil || err.Error() != "regex not allowed at position 6" {
+		t.Errorf("got %v", err)
+	}
+
+	var se *SyntaxError
+	rule, _ = Parse("nope=1")
+	if err := rule.Check(configs, &Policy{}); !errors.As(err, &se) {
+		t.Errorf("unknown tag: got %v", err)
+	}
+
+	if n := len(FilterConfigs(configs, &Policy{Roles: []string{"guest"}})); n != len(configs)-1 {
+		t.Errorf("FilterConfigs got %d configs", n)
+	}
+}
+
+func TestMaxNesting(t *testing.T) {
+	_, err := Parse(strings.Repeat("(", MaxNesting) + "a=1" + strings.Repeat(")", MaxNesting))
+	var se *SyntaxError
+	if !errors.As(err, &se) || se.Pos != MaxNesting {
+		t.Errorf("got %v", err)
+	}
+	if _, err := Parse(strings.Repeat("!(", MaxNesting/2-1) + "a=1" + strings.Repeat(")", MaxNesting/2-1)); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,115 @@
+package dsl
+
+import (
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+)
+
+// 正则安全限制，~=在解析时校验。
+// Mongo使用回溯型的PCRE引擎，嵌套量词等结构会导致灾难性回溯，因此一律拒绝。
+var (
+	// MaxRegexLength 正则的最大长度(字符数)
+	MaxRegexLength = 256
+	// RegexDenyList 禁止出现的片段
+	RegexDenyList = []string{".*.*", ".+.+", ".*.+", ".+.*"}
+)
+
+// 正则字面量 /pattern/flags 支持的flag
+const (
+	regexFlagIgnoreCase = 'i' // 不区分大小写
+	regexFlagMultiLine  = 'm' // ^$匹配每一行
+	regexFlagDotAll     = 's' // .匹配换行
+	regexFlagAnchored   = 'a' // 完整匹配，等同于 ^(?:pattern)$
+)
+
+// 返回最终的正则和Mongo风格的options(i/m/s的组合)。
+// ~="text" 与旧版本保持一致，不区分大小写；~=/pattern/flags 默认区分大小写
+func regexOf(v Value) (pattern, options string) {
+	if v.Kind != ValueRegex {
+		return v.Text, "i"
+	}
+	pattern = v.Text
+	for _, flag := range []rune{regexFlagIgnoreCase, regexFlagMultiLine, regexFlagDotAll} {
+		if strings.ContainsRune(v.Flags, flag) {
+			options += string(flag)
+		}
+	}
+	if strings.ContainsRune(v.Flags, regexFlagAnchored) {
+		pattern = "^(?:" + pattern + ")$"
+	}
+	return pattern, options
+}
+
+// 转换为Go/RE2的正则，options以内联flag的形式附加
+func inlineRegex(pattern, options string) string {
+	if options == "" {
+		return pattern
+	}
+	return "(?" + options + ")" + pattern
+}
+
+// 校验正则的flag、长度、禁用片段和嵌套量词
+func checkRegexValue(v Value) error {
+	if v.Kind == ValueRegex {
+		for _, flag := range v.Flags {
+			switch flag {
+			case regexFlagIgnoreCase, regexFlagMultiLine, regexFlagDotAll, regexFlagAnchored:
+			default:
+				return newSyntaxError(v.Pos(), "regex flags i, m, s or a", v.Flags)
+			}
+		}
+	}
+	if n := len([]rune(v.Text)); MaxRegexLength > 0 && n > MaxRegexLength {
+		return newSyntaxError(v.Pos(), fmt.Sprintf("regex of at most %d characters", MaxRegexLength), fmt.Sprintf("%d characters", n))
+	}
+	for _, deny := range RegexDenyList {
+		if strings.Contains(v.Text, deny) {
+			return newSyntaxError(v.Pos(), "regex without "+deny, v.Text)
+		}
+	}
+	pattern, options := regexOf(v)
+	if _, err := regexp.Compile(inlineRegex(pattern, options)); err != nil {
+		return newSyntaxError(v.Pos(), "valid regular expression", err.Error())
+	}
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return newSyntaxError(v.Pos(), "valid regular expression", err.Error())
+	}
+	if nestedRepeat(re, false) {
+		return newSyntaxError(v.Pos(), "regex without nested quantifiers", v.Text)
+	}
+	return nil
+}
+
+// 量词内部是否还有量词，如 (a+)+、(a|aa)*、(\w*){10}
+func nestedRepeat(re *syntax.Regexp, inRepeat bool) bool {
+	repeat := false
+	switch re.Op {
+	case syntax.OpStar, syntax.OpPlus:
+		repeat = true
+	case syntax.OpRepeat:
+		repeat = re.Max == -1 || re.Max > 1
+	}
+	if repeat && inRepeat {
+		return true
+	}
+	for _, sub := range re.Sub {
+		if nestedRepeat(sub, inRepeat || repeat) {
+			return true
+		}
+	}
+	return false
+}
+
+// ~=只允许用于开启了Regex的字符串tag
+func checkRegexConfig(n *CompareExpr, currentConfig *Config) error {
+	if currentConfig.Type != ConfigTypeString {
+		return newSyntaxError(n.OpPos, "operator supported by "+currentConfig.Type+" tag "+n.Tag, n.Op)
+	}
+	if !currentConfig.Regex {
+		return newSyntaxError(n.OpPos, "operator supported by tag "+n.Tag+", regex search is not enabled", n.Op)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package dsl
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestRegex(t *testing.T) {
+	cases := []struct {
+		dsl  string
+		want bson.D
+	}{
+		{"server~=\"^nginx.*\"", bson.D{{Key: "server", Value: bson.M{"$regex": "^nginx.*", "$options": "i"}}}},
+		{"server~=/nginx\\/[\\d.]+/", bson.D{{Key: "server", Value: bson.M{"$regex": `nginx/[\d.]+`}}}},
+		{"server~=/nginx/im", bson.D{{Key: "server", Value: bson.M{"$regex": "nginx", "$options": "im"}}}},
+		{"server~=/nginx|apache/ai", bson.D{{Key: "server", Value: bson.M{"$regex": "^(?:nginx|apache)$", "$options": "i"}}}},
+	}
+	for _, c := range cases {
+		got, err := Dsl2Mongo(c.dsl, webSearchConfigs)
+		if err != nil {
+			t.Fatalf("%s: %v", c.dsl, err)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got %v, want %v", c.dsl, got, c.want)
+		}
+	}
+
+	rule, err := Parse("server~=/^NGINX\\/1\\.\\d+$/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for s, want := range map[string]bool{"NGINX/1.18": true, "nginx/1.18": false, "NGINX/1x18": false} {
+		got, err := rule.Match(webSearchConfigs, map[string]string{"server": s})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("match %s got %v, want %v", s, got, want)
+		}
+	}
+}
+
+func TestRegexLimits(t *testing.T) {
+	cases := []struct {
+		dsl      string
+		expected string
+	}{
+		{"server~=/(a+)+$/", "regex without nested quantifiers"},
+		{"server~=/(\\w*){10}/", "regex without nested quantifiers"},
+		{"server~=/a.*.*b/", "regex without .*.*"},
+		{"server~=/[a-/", "valid regular expression"},
+		{"server~=/(?=a)/", "valid regular expression"},
+		{"server~=/a/x", "regex flags i, m, s or a"},
+		{"server~=/" + strings.Repeat("a", MaxRegexLength+1) + "/", "regex of at most 256 characters"},
+		{"server~=/abc", "closing /"},
+		{"server=/abc/", "value"},
+		{"resp~=/abc/", "operator supported by tag resp, regex search is not enabled"},
+		{"status_code~=/1/", "operator supported by number tag status_code"},
+	}
+	for _, c := range cases {
+		_, err := Dsl2Mongo(c.dsl, webSearchConfigs)
+		var serr *SyntaxError
+		if !errors.As(err, &serr) {
+			t.Errorf("%s: expect *SyntaxError, got %v", c.dsl, err)
+			continue
+		}
+		if serr.Expected != c.expected {
+			t.Errorf("%s: got %+v, want expected %s", c.dsl, *serr, c.expected)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package dsl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Dialect SQL方言
+type Dialect string
+
+const (
+	DialectMySQL      Dialect = "mysql"
+	DialectClickHouse Dialect = "clickhouse"
+)
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// ToSQL 将查询语句编译为参数化的WHERE条件(不含WHERE关键字)，取值全部通过占位符?传入args。
+// 字符串的=和!=为不区分大小写的包含/不包含：MySQL使用LIKE(大小写取决于字段collation)，
+// ClickHouse使用positionCaseInsensitiveUTF8；~=使用REGEXP_LIKE/match；
+// tag=*和exists(tag)编译为IS NOT NULL。
+func (r *Rule) ToSQL(configs []Config, dialect Dialect) (string, []interface{}, error) {
+	if r == nil || r.root == nil {
+		return "", nil, newSyntaxError(0, "expression", tokenEOF)
+	}
+	if dialect != DialectMySQL && dialect != DialectClickHouse {
+		return "", nil, fmt.Errorf("unknown sql dialect %s", dialect)
+	}
+	b := &sqlBuilder{dialect: dialect, configs: configs}
+	if err := b.build(r.root); err != nil {
+		return "", nil, err
+	}
+	return b.sb.String(), b.args, nil
+}
+
+func Dsl2SQL(dsl string, configs []Config, dialect Dialect) (string, []interface{}, error) {
+	exp, err := Parse(dsl)
+	if err != nil {
+		return "", nil, err
+	}
+	return exp.ToSQL(configs, dialect)
+}
+
+type sqlBuilder struct {
+	dialect Dialect
+	configs []Config
+	sb      strings.Builder
+	args    []interface{}
+}
+
+// 引用字段名。MySQL中host.country视为表名.字段名，ClickHouse中视为Nested字段的完整名称
+func (b *sqlBuilder) quote(column string) string {
+	if b.dialect == DialectClickHouse {
+		return "`" + strings.ReplaceAll(column, "`", "``") + "`"
+	}
+	parts := strings.Split(column, ".")
+	for i, part := range parts {
+		parts[i] = "`" + strings.ReplaceAll(part, "`", "``") + "`"
+	}
+	return strings.Join(parts, ".")
+}
+
+func (b *sqlBuilder) write(format string, args ...interface{}) {
+	b.sb.WriteString(format)
+	b.args = append(b.args, args...)
+}
+
+func (b *sqlBuilder) build(node Node) error {
+	switch n := node.(type) {
+	case *ParenExpr:
+		return b.build(n.X)
+	case *NotExpr:
+		b.write("NOT (")
+		if err := b.build(n.X); err != nil {
+			return err
+		}
+		b.write(")")
+		return nil
+	case *BinaryExpr:
+		op := " AND "
+		if n.Op == OpOr {
+			op = " OR "
+		}
+		b.write("(")
+		if err := b.build(n.X); err != nil {
+			return err
+		}
+		b.write(op)
+		if err := b.build(n.Y); err != nil {
+			return err
+		}
+		b.write(")")
+		return nil
+	case *CompareExpr:
+		return b.compare(n)
+	case *RangeExpr:
+		currentConfig, column, err := lookupConfig(b.configs, n.Tag, n.TagPos)
+		if err != nil {
+			return err
+		}
+		if currentConfig.Type == ConfigTypeBool {
+			return newSyntaxError(n.Lower.Pos(), "comparable value, bool tag "+n.Tag+" not support range query", "range")
+		}
+		lower, err := valueOf(n.Lower, currentConfig)
+		if err != nil {
+			return err
+		}
+		upper, err := valueOf(n.Upper, currentConfig)
+		if err != nil {
+			return err
+		}
+		lowerOp, upperOp := ">", "<"
+		if n.LowerInclusive {
+			lowerOp = ">="
+		}
+		if n.UpperInclusive {
+			upperOp = "<="
+		}
+		column = b.quote(column)
+		b.write("("+column+" "+lowerOp+" ? AND "+column+" "+upperOp+" ?)", lower, upper)
+		return nil
+	case *InExpr:
+		currentConfig, column, err := lookupConfig(b.configs, n.Tag, n.TagPos)
+		if err != nil {
+			return err
+		}
+		values := make([]interface{}, 0, len(n.Values))
+		for _, value := range n.Values {
+			v, err := valueOf(value, currentConfig)
+			if err != nil {
+				return err
+			}
+			values = append(values, v)
+		}
+		op := " IN ("
+		if n.Not {
+			op = " NOT IN ("
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(values)), ", ")
+		b.write(b.quote(column)+op+placeholders+")", values...)
+		return nil
+	case *ExistsExpr:
+		_, column, err := lookupConfig(b.configs, n.Tag, n.TagPos)
+		if err != nil {
+			return err
+		}
+		if n.Exists {
+			b.write(b.quote(column) + " IS NOT NULL")
+		} else {
+			b.write(b.quote(column) + " IS NULL")
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown node type %T", node)
+}
+
+func (b *sqlBuilder) compare(n *CompareExpr) error {
+	currentConfig, column, err := lookupConfig(b.configs, n.Tag, n.TagPos)
+	if err != nil {
+		return err
+	}
+	column = b.quote(column)
+	text := n.Value.Text
+	isString := currentConfig.Type == ConfigTypeString
+
+	switch n.Op {
+	case tokenContains, tokenNotEqual:
+		if !isString {
+			v, err := valueOf(n.Value, currentConfig)
+			if err != nil {
+				return err
+			}
+			op := " = ?"
+			if n.Op == tokenNotEqual {
+				op = " != ?"
+			}
+			b.write(column+op, v)
+			return nil
+		}
+		not := n.Op == tokenNotEqual
+		if b.dialect == DialectClickHouse {
+			if not {
+				b.write("positionCaseInsensitiveUTF8("+column+", ?) = 0", text)
+			} else {
+				b.write("positionCaseInsensitiveUTF8("+column+", ?) > 0", text)
+			}
+			return nil
+		}
+		if not {
+			b.write(column+" NOT LIKE ?", "%"+likeEscaper.Replace(text)+"%")
+		} else {
+			b.write(column+" LIKE ?", "%"+likeEscaper.Replace(text)+"%")
+		}
+		return nil
+	case tokenFullEqual:
+		v, err := valueOf(n.Value, currentConfig)
+		if err != nil {
+			return err
+		}
+		b.write(column+" = ?", v)
+		return nil
+	case tokenRegexEqual:
+		if err := checkRegexConfig(n, currentConfig); err != nil {
+			return err
+		}
+		pattern, options := regexOf(n.Value)
+		if b.dialect == DialectClickHouse {
+			b.write("match("+column+", ?)", inlineRegex(pattern, options))
+			return nil
+		}
+		// REGEXP_LIKE的match_type：c区分大小写，n对应s
+		matchType := "c"
+		for _, flag := range options {
+			switch flag {
+			case regexFlagIgnoreCase:
+				matchType = "i"
+			case regexFlagMultiLine:
+				matchType += "m"
+			case regexFlagDotAll:
+				matchType += "n"
+			}
+		}
+		b.write("REGEXP_LIKE("+column+", ?, ?)", pattern, matchType)
+		return nil
+	case tokenGreater, tokenGreaterEqual, tokenLess, tokenLessEqual:
+		if currentConfig.Type == ConfigTypeBool {
+			return newSyntaxError(n.OpPos, "operator supported by bool tag "+n.Tag, n.Op)
+		}
+		v, err := valueOf(n.Value, currentConfig)
+		if err != nil {
+			return err
+		}
+		b.write(column+" "+n.Op+" ?", v)
+		return nil
+	}
+	return newSyntaxError(n.OpPos, "operator", n.Op)
+}
@@ -0,0 +1,77 @@
+package dsl
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestToSQL(t *testing.T) {
+	cases := []struct {
+		dsl        string
+		mysql      string
+		clickhouse string
+		args       []interface{}
+	}{
+		{
+			dsl:        "domain=\"50%_off\" && status_code==200",
+			mysql:      "(`domain` LIKE ? AND `statuscode` = ?)",
+			clickhouse: "(positionCaseInsensitiveUTF8(`domain`, ?) > 0 AND `statuscode` = ?)",
+		},
+		{
+			dsl:        "status_code=200 || !(status_code=[200 TO 299})",
+			mysql:      "(`statuscode` = ? OR NOT ((`statuscode` >= ? AND `statuscode` < ?)))",
+			clickhouse: "(`statuscode` = ? OR NOT ((`statuscode` >= ? AND `statuscode` < ?)))",
+			args:       []interface{}{200, 200, 299},
+		},
+		{
+			dsl:        "country!=\"中国\"",
+			mysql:      "`host`.`country` NOT LIKE ?",
+			clickhouse: "positionCaseInsensitiveUTF8(`host.country`, ?) = 0",
+		},
+		{
+			dsl:        "status_code not in (404, 500) && tag=* && is_req==true",
+			mysql:      "((`statuscode` NOT IN (?, ?) AND `tags`.`name` IS NOT NULL) AND `is_req` = ?)",
+			clickhouse: "((`statuscode` NOT IN (?, ?) AND `tags.name` IS NOT NULL) AND `is_req` = ?)",
+			args:       []interface{}{404, 500, true},
+		},
+		{
+			dsl:        "server~=\"nginx\" && status_code>=400",
+			mysql:      "(REGEXP_LIKE(`server`, ?, ?) AND `statuscode` >= ?)",
+			clickhouse: "(match(`server`, ?) AND `statuscode` >= ?)",
+		},
+	}
+	for _, c := range cases {
+		mysql, mysqlArgs, err := Dsl2SQL(c.dsl, webSearchConfigs, DialectMySQL)
+		if err != nil {
+			t.Fatalf("%s: %v", c.dsl, err)
+		}
+		if mysql != c.mysql {
+			t.Errorf("%s: mysql got %s, want %s", c.dsl, mysql, c.mysql)
+		}
+		ch, chArgs, err := Dsl2SQL(c.dsl, webSearchConfigs, DialectClickHouse)
+		if err != nil {
+			t.Fatalf("%s: %v", c.dsl, err)
+		}
+		if ch != c.clickhouse {
+			t.Errorf("%s: clickhouse got %s, want %s", c.dsl, ch, c.clickhouse)
+		}
+		if c.args != nil {
+			if !reflect.DeepEqual(mysqlArgs, c.args) || !reflect.DeepEqual(chArgs, c.args) {
+				t.Errorf("%s: args got %v %v, want %v", c.dsl, mysqlArgs, chArgs, c.args)
+			}
+		}
+	}
+
+	_, args, _ := Dsl2SQL("domain=\"50%_off\\\\\" && server!=\"a\"", webSearchConfigs, DialectMySQL)
+	if want := []interface{}{`%50\%\_off\\%`, "%a%"}; !reflect.DeepEqual(args, want) {
+		t.Errorf("like escape got %v, want %v", args, want)
+	}
+	_, args, _ = Dsl2SQL("server~=\"a.b\"", webSearchConfigs, DialectClickHouse)
+	if want := []interface{}{`(?i)a.b`}; !reflect.DeepEqual(args, want) {
+		t.Errorf("regex got %v, want %v", args, want)
+	}
+	_, args, _ = Dsl2SQL("server~=/nginx\\/\\d+/sa", webSearchConfigs, DialectMySQL)
+	if want := []interface{}{`^(?:nginx/\d+)$`, "cn"}; !reflect.DeepEqual(args, want) {
+		t.Errorf("regex got %v, want %v", args, want)
+	}
+}
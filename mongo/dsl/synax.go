@@ -1,142 +1,95 @@
 package dsl
 
 import (
-	"errors"
+	"fmt"
 	"regexp"
 	"strconv"
+	"sync"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 )
 
-type Exp interface {
-	Name() string
-}
-
 type Rule struct {
-	exps []Exp
-}
-
-type dslExp struct {
-	p1 string
-	p2 string
-	p3 string
-}
-
-func (d dslExp) Name() string {
-	return "dslExp"
-}
-
-type logicExp struct {
-	p string
+	root    Node
+	regexps sync.Map // Match使用的正则缓存
 }
 
-func (l logicExp) Name() string {
-	return "logicExp"
+// Root 返回查询语句的语法树
+func (r *Rule) Root() Node {
+	return r.root
 }
 
-type bracketExp struct {
-	p string
+// String 返回规范化的查询语句
+func (r *Rule) String() string {
+	if r == nil || r.root == nil {
+		return ""
+	}
+	return r.root.String()
 }
 
-func (b bracketExp) Name() string {
-	return "bracketExp"
+// Parse 解析查询语句
+func Parse(dsl string) (*Rule, error) {
+	tokens, err := ParseTokens(dsl)
+	if err != nil {
+		return nil, err
+	}
+	return TransFormExp(tokens)
 }
 
 func TransFormExp(tokens []Token) (*Rule, error) {
-	stream := newTokenStream(tokens)
-	var ret []Exp
-	for stream.hasNext() {
-		tmpToken, err := stream.next()
-		if err != nil {
-			return nil, err
-		}
-		switch tmpToken.name {
-		case tokenTag:
-			p2, err := stream.next()
-			if err != nil {
-				return nil, err
-			}
-			if !(p2.name == tokenContains || p2.name == tokenFullEqual || p2.name == tokenNotEqual || p2.name == tokenRegexEqual) {
-				return nil, errors.New("synax error in " + tmpToken.content + " " + p2.content)
-			}
-			p3, err := stream.next()
-			if err != nil {
-				return nil, err
-			}
-			if !(p3.name == tokenText || p3.name == tokenNumber || p3.name == tokenBool) {
-				return nil, errors.New("synax error in " + tmpToken.content + " " + p2.content + " " + p3.content)
-			}
-			// 正则缓存对象
-			var dsl dslExp
-			if p2.name == tokenRegexEqual {
-				_, err := regexp.Compile(p3.content)
-				if err != nil {
-					return nil, err
-				}
-			}
-			dsl = dslExp{p1: tmpToken.content, p2: p2.content, p3: p3.content}
-			ret = append(ret, &dsl)
-		case tokenAnd, tokenOr:
-			exp := &logicExp{tmpToken.content}
-			ret = append(ret, exp)
-		case tokenLeftBracket, tokenRightBracket:
-			exp := &bracketExp{tmpToken.content}
-			ret = append(ret, exp)
-		}
-	}
-	ret, err := infix2ToPostfix(ret)
+	root, err := parse(tokens)
 	if err != nil {
 		return nil, err
 	}
+	if err := checkRegex(root); err != nil {
+		return nil, err
+	}
 	rule := new(Rule)
-	rule.exps = ret
+	rule.root = root
 	return rule, nil
 }
 
-// 中缀表达式转换为后缀表达式
-func infix2ToPostfix(exps []Exp) ([]Exp, error) {
-	stack := NewStack()
-	var ret []Exp
-	for i := 0; i < len(exps); i++ {
-		switch tmpExp := exps[i].(type) {
-		case *dslExp:
-			ret = append(ret, tmpExp)
-		case *bracketExp:
-			if tmpExp.p == tokenLeftBracket {
-				// 左括号直接入栈
-				stack.push(tmpExp)
-			} else if tmpExp.p == tokenRightBracket {
-				// 右括号则弹出元素,直到遇到左括号
-				for !stack.isEmpty() {
-					pre, exist := stack.top().(*bracketExp)
-					if exist && pre.p == tokenLeftBracket {
-						stack.pop()
-						break
-					}
-					ret = append(ret, stack.pop().(Exp))
-				}
-			}
-		case *logicExp:
-			if !stack.isEmpty() {
-				top := stack.top()
-				bracket, exist := top.(*bracketExp)
-				if exist && bracket.p == tokenLeftBracket {
-					stack.push(tmpExp)
-					continue
-				}
-				ret = append(ret, top.(Exp))
-				stack.pop()
-			}
-			stack.push(tmpExp)
-		default:
-			return nil, errors.New("unknown transform type")
+// 提前校验正则的安全限制，错误位置指向正则本身
+func checkRegex(node Node) error {
+	switch n := node.(type) {
+	case *BinaryExpr:
+		if err := checkRegex(n.X); err != nil {
+			return err
+		}
+		return checkRegex(n.Y)
+	case *NotExpr:
+		return checkRegex(n.X)
+	case *ParenExpr:
+		return checkRegex(n.X)
+	case *CompareExpr:
+		if n.Op == OpRegex {
+			return checkRegexValue(n.Value)
 		}
 	}
-	for !stack.isEmpty() {
-		tmp := stack.pop()
-		ret = append(ret, tmp.(Exp))
+	return nil
+}
+
+func findConfig(configs []Config, tagName string) *Config {
+	for i := range configs {
+		if configs[i].TagName == tagName {
+			return &configs[i]
+		}
 	}
-	return ret, nil
+	return nil
+}
+
+// 查找tag对应的配置，返回配置和查询使用的字段名
+func lookupConfig(configs []Config, tag string, pos int) (*Config, string, error) {
+	currentConfig := findConfig(configs, tag)
+	if currentConfig == nil {
+		return nil, "", newSyntaxError(pos, "known tag name", tag)
+	}
+	column := tag
+	if currentConfig.ColumnName != "" {
+		column = currentConfig.ColumnName // 设定column
+	}
+	return currentConfig, column, nil
 }
 
 func handleType(text string, currentConfig *Config) (interface{}, error) {
@@ -145,7 +98,24 @@ func handleType(text string, currentConfig *Config) (interface{}, error) {
 	case ConfigTypeString:
 		v = text
 	case ConfigTypeNumber:
-		v, _ = strconv.Atoi(text)
+		if i, err := strconv.Atoi(text); err == nil {
+			return i, nil
+		}
+		f, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("tag %s expect a number, got %q", currentConfig.TagName, text)
+		}
+		v = f
+	case ConfigTypeDate:
+		layout := currentConfig.Layout
+		if layout == "" {
+			layout = DefaultDateLayout
+		}
+		t, err := time.Parse(layout, text)
+		if err != nil {
+			return nil, fmt.Errorf("tag %s expect a date in layout %s, got %q", currentConfig.TagName, layout, text)
+		}
+		v = t
 	case ConfigTypeBool:
 		if text == "true" || text == "TRUE" {
 			v = true
@@ -156,148 +126,231 @@ func handleType(text string, currentConfig *Config) (interface{}, error) {
 	return v, nil
 }
 
-func (r *Rule) ToMongo(configs []Config) (bson.D, error) {
-	stack := NewStack()
-	for i := 0; i < len(r.exps); i++ {
-		switch next := r.exps[i].(type) {
-		case *dslExp:
-			s1 := next.p1 // tag name
-			var currentConfig *Config
-
-			for _, config := range configs {
-				if config.TagName == s1 {
-					currentConfig = &config
-					break
-				}
-			}
+// 转换字面量，错误位置指向字面量
+func valueOf(value Value, currentConfig *Config) (interface{}, error) {
+	v, err := handleType(value.Text, currentConfig)
+	if err != nil {
+		return nil, newSyntaxError(value.Pos(), currentConfig.Type+" value for tag "+currentConfig.TagName, strconv.Quote(value.Text))
+	}
+	return v, nil
+}
 
-			if currentConfig == nil {
-				return nil, errors.New("unknown tag name")
-			}
+var compareOperators = map[string]string{
+	tokenGreater:      "$gt",
+	tokenGreaterEqual: "$gte",
+	tokenLess:         "$lt",
+	tokenLessEqual:    "$lte",
+}
 
-			var r bson.D
-			text := next.p3 // value
-			if currentConfig.ColumnName != "" {
-				s1 = currentConfig.ColumnName // 设定column
-			}
+func (r *Rule) ToMongo(configs []Config) (bson.D, error) {
+	if r == nil || r.root == nil {
+		return nil, newSyntaxError(0, "expression", tokenEOF)
+	}
+	return toMongo(r.root, configs)
+}
 
-			switch next.p2 {
-			case tokenFullEqual:
-				v, err := handleType(text, currentConfig)
-				if err != nil {
-					return nil, err
-				}
-				r = bson.D{
-					{
-						Key: s1,
-						Value: bson.M{
-							"$eq": v,
-						},
-					},
-				}
-			case tokenContains:
-				if currentConfig.Type == ConfigTypeString {
-					text = regexp.QuoteMeta(text)
-				}
-				v, err := handleType(text, currentConfig)
-				if err != nil {
-					return nil, err
-				}
-				if currentConfig.Type == ConfigTypeString {
-					r = bson.D{
-						{
-							Key:   s1,
-							Value: bson.M{"$regex": v, "$options": "i"},
-						},
-					}
-				} else {
-					r = bson.D{
-						{
-							Key:   s1,
-							Value: v,
-						},
-					}
-				}
-			case tokenNotEqual:
-				if currentConfig.Type == ConfigTypeString {
-					text = regexp.QuoteMeta(text)
-				}
-				v, err := handleType(text, currentConfig)
-				if err != nil {
-					return nil, err
-				}
-				if currentConfig.Type == ConfigTypeString {
-					r = bson.D{
-						{
-							Key: s1,
-							Value: bson.M{"$not": bson.M{
-								"$regex":   v,
-								"$options": "i",
-							}},
-						},
-					}
-				} else {
-					r = bson.D{
-						{
-							Key:   s1,
-							Value: bson.M{"$ne": v},
-						},
-					}
-				}
-			case tokenRegexEqual:
-				if currentConfig.Type == ConfigTypeString {
-					text = regexp.QuoteMeta(text)
-				} else {
-					return nil, errors.New("regex type not support ~=")
-				}
-				// 小写模糊
-				r = bson.D{
-					{
-						Key:   s1,
-						Value: bson.M{"$regex": text, "$options": "i"},
-					},
-				}
-			default:
-				panic("unknown p2 token")
-			}
-			stack.push(r)
-		case *logicExp:
-			p1 := stack.pop().(bson.D)
-			p2 := stack.pop().(bson.D)
-			var r bson.D
-			switch next.p {
-			case tokenAnd:
-				r = bson.D{
-					{
-						Key:   "$and",
-						Value: []bson.D{p1, p2},
-					},
-				}
-			case tokenOr:
-				r = bson.D{
-					{
-						Key:   "$or",
-						Value: []bson.D{p1, p2},
-					},
-				}
-			default:
-				panic("unknown logic type")
+func toMongo(node Node, configs []Config) (bson.D, error) {
+	switch n := node.(type) {
+	case *ParenExpr:
+		return toMongo(n.X, configs)
+	case *NotExpr:
+		p1, err := toMongo(n.X, configs)
+		if err != nil {
+			return nil, err
+		}
+		return bson.D{
+			{
+				Key:   "$nor",
+				Value: []bson.D{p1},
+			},
+		}, nil
+	case *BinaryExpr:
+		p1, err := toMongo(n.X, configs)
+		if err != nil {
+			return nil, err
+		}
+		p2, err := toMongo(n.Y, configs)
+		if err != nil {
+			return nil, err
+		}
+		key := "$and"
+		if n.Op == OpOr {
+			key = "$or"
+		}
+		return bson.D{
+			{
+				Key:   key,
+				Value: []bson.D{p1, p2},
+			},
+		}, nil
+	case *CompareExpr:
+		return compareToMongo(n, configs)
+	case *RangeExpr:
+		currentConfig, s1, err := lookupConfig(configs, n.Tag, n.TagPos)
+		if err != nil {
+			return nil, err
+		}
+		if currentConfig.Type == ConfigTypeBool {
+			return nil, newSyntaxError(n.Lower.Pos(), "comparable value, bool tag "+n.Tag+" not support range query", "range")
+		}
+		lower, err := valueOf(n.Lower, currentConfig)
+		if err != nil {
+			return nil, err
+		}
+		upper, err := valueOf(n.Upper, currentConfig)
+		if err != nil {
+			return nil, err
+		}
+		cond := bson.M{"$gt": lower, "$lt": upper}
+		if n.LowerInclusive {
+			delete(cond, "$gt")
+			cond["$gte"] = lower
+		}
+		if n.UpperInclusive {
+			delete(cond, "$lt")
+			cond["$lte"] = upper
+		}
+		return bson.D{
+			{
+				Key:   s1,
+				Value: cond,
+			},
+		}, nil
+	case *InExpr:
+		currentConfig, s1, err := lookupConfig(configs, n.Tag, n.TagPos)
+		if err != nil {
+			return nil, err
+		}
+		values := make([]interface{}, 0, len(n.Values))
+		for _, value := range n.Values {
+			v, err := valueOf(value, currentConfig)
+			if err != nil {
+				return nil, err
 			}
-			stack.push(r)
-		default:
-			panic("error eval")
+			values = append(values, v)
+		}
+		op := "$in"
+		if n.Not {
+			op = "$nin"
 		}
+		return bson.D{
+			{
+				Key:   s1,
+				Value: bson.M{op: values},
+			},
+		}, nil
+	case *ExistsExpr:
+		_, s1, err := lookupConfig(configs, n.Tag, n.TagPos)
+		if err != nil {
+			return nil, err
+		}
+		return bson.D{
+			{
+				Key:   s1,
+				Value: bson.M{"$exists": n.Exists},
+			},
+		}, nil
 	}
-	top := stack.top().(bson.D)
-	return top, nil
+	return nil, fmt.Errorf("unknown node type %T", node)
 }
 
-func Dsl2Mongo(dsl string, configs []Config) (bson.D, error) {
-	tokens, err := ParseTokens(dsl)
+func compareToMongo(n *CompareExpr, configs []Config) (bson.D, error) {
+	currentConfig, s1, err := lookupConfig(configs, n.Tag, n.TagPos)
 	if err != nil {
 		return nil, err
 	}
-	exp, err := TransFormExp(tokens)
+	text := n.Value.Text // value
+
+	switch n.Op {
+	case tokenFullEqual:
+		v, err := valueOf(n.Value, currentConfig)
+		if err != nil {
+			return nil, err
+		}
+		return bson.D{
+			{
+				Key: s1,
+				Value: bson.M{
+					"$eq": v,
+				},
+			},
+		}, nil
+	case tokenContains:
+		if currentConfig.Type == ConfigTypeString {
+			return bson.D{
+				{
+					Key:   s1,
+					Value: bson.M{"$regex": regexp.QuoteMeta(text), "$options": "i"},
+				},
+			}, nil
+		}
+		v, err := valueOf(n.Value, currentConfig)
+		if err != nil {
+			return nil, err
+		}
+		return bson.D{
+			{
+				Key:   s1,
+				Value: v,
+			},
+		}, nil
+	case tokenNotEqual:
+		if currentConfig.Type == ConfigTypeString {
+			return bson.D{
+				{
+					Key: s1,
+					Value: bson.M{"$not": bson.M{
+						"$regex":   regexp.QuoteMeta(text),
+						"$options": "i",
+					}},
+				},
+			}, nil
+		}
+		v, err := valueOf(n.Value, currentConfig)
+		if err != nil {
+			return nil, err
+		}
+		return bson.D{
+			{
+				Key:   s1,
+				Value: bson.M{"$ne": v},
+			},
+		}, nil
+	case tokenRegexEqual:
+		if err := checkRegexConfig(n, currentConfig); err != nil {
+			return nil, err
+		}
+		pattern, options := regexOf(n.Value)
+		cond := bson.M{"$regex": pattern}
+		if options != "" {
+			cond["$options"] = options
+		}
+		return bson.D{
+			{
+				Key:   s1,
+				Value: cond,
+			},
+		}, nil
+	case tokenGreater, tokenGreaterEqual, tokenLess, tokenLessEqual:
+		if currentConfig.Type == ConfigTypeBool {
+			return nil, newSyntaxError(n.OpPos, "operator supported by bool tag "+n.Tag, n.Op)
+		}
+		v, err := valueOf(n.Value, currentConfig)
+		if err != nil {
+			return nil, err
+		}
+		return bson.D{
+			{
+				Key:   s1,
+				Value: bson.M{compareOperators[n.Op]: v},
+			},
+		}, nil
+	}
+	return nil, newSyntaxError(n.OpPos, "operator", n.Op)
+}
+
+func Dsl2Mongo(dsl string, configs []Config) (bson.D, error) {
+	exp, err := Parse(dsl)
 	if err != nil {
 		return nil, err
 	}
@@ -1,9 +1,13 @@
 package dsl
 
 import (
+	"errors"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/kr/pretty"
+	"go.mongodb.org/mongo-driver/bson"
 )
 
 var webSearchConfigs = []Config{
@@ -13,6 +17,7 @@ var webSearchConfigs = []Config{
 		Description: "通过域名查询",
 		Example:     "domain=\"example.com\"",
 		Type:        ConfigTypeString,
+		Regex:       true,
 	},
 	{
 		TagName:     "favicon_md5",
@@ -76,6 +81,7 @@ var webSearchConfigs = []Config{
 		Description: "server查询",
 		Example:     "server=\"nginx\"",
 		Type:        ConfigTypeString,
+		Regex:       true,
 	},
 	{
 		TagName:     "status_code",
@@ -136,3 +142,224 @@ func TestTransFormExp(t *testing.T) {
 	}
 	pretty.Println(dsl)
 }
+
+func TestCompareAndRange(t *testing.T) {
+	configs := append([]Config{
+		{
+			TagName:    "date",
+			ColumnName: "created",
+			Type:       ConfigTypeDate,
+		},
+	}, webSearchConfigs...)
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		dsl  string
+		want bson.D
+	}{
+		{"status_code>200", bson.D{{Key: "statuscode", Value: bson.M{"$gt": 200}}}},
+		{"status_code>=200", bson.D{{Key: "statuscode", Value: bson.M{"$gte": 200}}}},
+		{"status_code<1.5", bson.D{{Key: "statuscode", Value: bson.M{"$lt": 1.5}}}},
+		{"status_code<=404", bson.D{{Key: "statuscode", Value: bson.M{"$lte": 404}}}},
+		{"port>\"8000\"", bson.D{{Key: "port", Value: bson.M{"$gt": "8000"}}}},
+		{"date>=\"2024-01-01\"", bson.D{{Key: "created", Value: bson.M{"$gte": day}}}},
+		{"status_code=[200 TO 299]", bson.D{{Key: "statuscode", Value: bson.M{"$gte": 200, "$lte": 299}}}},
+		{"status_code={200 to 299]", bson.D{{Key: "statuscode", Value: bson.M{"$gt": 200, "$lte": 299}}}},
+		{"date=[\"2024-01-01\" TO \"2024-01-01\"}", bson.D{{Key: "created", Value: bson.M{"$gte": day, "$lt": day}}}},
+	}
+	for _, c := range cases {
+		got, err := Dsl2Mongo(c.dsl, configs)
+		if err != nil {
+			t.Fatalf("%s: %v", c.dsl, err)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got %v, want %v", c.dsl, got, c.want)
+		}
+	}
+
+	for _, s := range []string{
+		"is_req=[true TO false]",
+		"is_req>true",
+		"status_code>\"abc\"",
+		"date<\"2024/01/01\"",
+		"status_code=[200 299]",
+		"status_code=[200 TO 299",
+	} {
+		if _, err := Dsl2Mongo(s, configs); err == nil {
+			t.Errorf("%s: expect error", s)
+		}
+	}
+}
+
+func TestNotInExists(t *testing.T) {
+	cases := []struct {
+		dsl  string
+		want bson.D
+	}{
+		{
+			"!(is_req==true)",
+			bson.D{{Key: "$nor", Value: []bson.D{{{Key: "is_req", Value: bson.M{"$eq": true}}}}}},
+		},
+		{
+			"status_code in (200, 301)",
+			bson.D{{Key: "statuscode", Value: bson.M{"$in": []interface{}{200, 301}}}},
+		},
+		{
+			"tag NOT IN (\"a\",\"b\")",
+			bson.D{{Key: "tags.name", Value: bson.M{"$nin": []interface{}{"a", "b"}}}},
+		},
+		{
+			"tag=*",
+			bson.D{{Key: "tags.name", Value: bson.M{"$exists": true}}},
+		},
+		{
+			"tag!=*",
+			bson.D{{Key: "tags.name", Value: bson.M{"$exists": false}}},
+		},
+		{
+			"exists(tag)",
+			bson.D{{Key: "tags.name", Value: bson.M{"$exists": true}}},
+		},
+		{
+			// !只作用于紧随其后的表达式
+			"!(tag=*) && is_req==true",
+			bson.D{{Key: "$and", Value: []bson.D{
+				{{Key: "$nor", Value: []bson.D{{{Key: "tags.name", Value: bson.M{"$exists": true}}}}}},
+				{{Key: "is_req", Value: bson.M{"$eq": true}}},
+			}}},
+		},
+		{
+			"is_req==true && !exists(tag) || status_code==200",
+			bson.D{{Key: "$or", Value: []bson.D{
+				{{Key: "$and", Value: []bson.D{
+					{{Key: "is_req", Value: bson.M{"$eq": true}}},
+					{{Key: "$nor", Value: []bson.D{{{Key: "tags.name", Value: bson.M{"$exists": true}}}}}},
+				}}},
+				{{Key: "statuscode", Value: bson.M{"$eq": 200}}},
+			}}},
+		},
+	}
+	for _, c := range cases {
+		got, err := Dsl2Mongo(c.dsl, webSearchConfigs)
+		if err != nil {
+			t.Fatalf("%s: %v", c.dsl, err)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got %v, want %v", c.dsl, got, c.want)
+		}
+	}
+
+	for _, s := range []string{
+		"tag in \"a\"",
+		"tag in (\"a\" \"b\")",
+		"tag not (\"a\")",
+		"exists(tag",
+		"status_code in (\"abc\")",
+	} {
+		if _, err := Dsl2Mongo(s, webSearchConfigs); err == nil {
+			t.Errorf("%s: expect error", s)
+		}
+	}
+}
+
+func TestPrecedence(t *testing.T) {
+	a := bson.D{{Key: "statuscode", Value: bson.M{"$eq": 1}}}
+	b := bson.D{{Key: "statuscode", Value: bson.M{"$eq": 2}}}
+	c := bson.D{{Key: "statuscode", Value: bson.M{"$eq": 3}}}
+	and := func(x, y bson.D) bson.D { return bson.D{{Key: "$and", Value: []bson.D{x, y}}} }
+	or := func(x, y bson.D) bson.D { return bson.D{{Key: "$or", Value: []bson.D{x, y}}} }
+	not := func(x bson.D) bson.D { return bson.D{{Key: "$nor", Value: []bson.D{x}}} }
+	cases := []struct {
+		dsl  string
+		want bson.D
+	}{
+		{"status_code==1 || status_code==2 && status_code==3", or(a, and(b, c))},
+		{"status_code==1 && status_code==2 || status_code==3", or(and(a, b), c)},
+		{"(status_code==1 || status_code==2) && status_code==3", and(or(a, b), c)},
+		{"status_code==1 && status_code==2 && status_code==3", and(and(a, b), c)},
+		{"!status_code==1 && status_code==2", and(not(a), b)},
+		{"!(status_code==1 || status_code==2)", not(or(a, b))},
+		{"!!status_code==1", not(not(a))},
+	}
+	for _, c := range cases {
+		got, err := Dsl2Mongo(c.dsl, webSearchConfigs)
+		if err != nil {
+			t.Fatalf("%s: %v", c.dsl, err)
+		}
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got %v, want %v", c.dsl, got, c.want)
+		}
+	}
+}
+
+func TestSyntaxError(t *testing.T) {
+	cases := []struct {
+		dsl  string
+		want SyntaxError
+	}{
+		{"", SyntaxError{Pos: 0, Expected: "tag name, ( or !", Got: "EOF"}},
+		{"title=\"abc", SyntaxError{Pos: 6, Expected: "closing \"", Got: "EOF"}},
+		{"title=\"a\" &&", SyntaxError{Pos: 12, Expected: "tag name, ( or !", Got: "EOF"}},
+		{"title=\"a\" title", SyntaxError{Pos: 10, Expected: "&& or ||", Got: "title"}},
+		{"(title=\"a\"", SyntaxError{Pos: 10, Expected: ")", Got: "EOF"}},
+		{"title \"a\"", SyntaxError{Pos: 6, Expected: "operator", Got: "\"a\""}},
+		{"title=&&", SyntaxError{Pos: 6, Expected: "value", Got: "&&"}},
+		{"title=\"a\" & x", SyntaxError{Pos: 10, Expected: "token", Got: "&"}},
+		{"port=[80 443]", SyntaxError{Pos: 9, Expected: "TO", Got: "443"}},
+		{"tag in (\"a\" \"b\")", SyntaxError{Pos: 12, Expected: ", or )", Got: "\"b\""}},
+		{"unknown=\"a\"", SyntaxError{Pos: 0, Expected: "known tag name", Got: "unknown"}},
+		{"is_req==true && status_code==abc", SyntaxError{Pos: 29, Expected: "value", Got: "abc"}},
+		{"is_req==true && status_code==\"abc\"", SyntaxError{Pos: 29, Expected: "number value for tag status_code", Got: "\"abc\""}},
+		{"is_req>true", SyntaxError{Pos: 6, Expected: "operator supported by bool tag is_req", Got: ">"}},
+	}
+	for _, c := range cases {
+		_, err := Dsl2Mongo(c.dsl, webSearchConfigs)
+		var serr *SyntaxError
+		if !errors.As(err, &serr) {
+			t.Errorf("%s: expect *SyntaxError, got %v", c.dsl, err)
+			continue
+		}
+		if *serr != c.want {
+			t.Errorf("%s: got %+v, want %+v", c.dsl, *serr, c.want)
+		}
+	}
+}
+
+func FuzzDsl2Mongo(f *testing.F) {
+	for _, config := range webSearchConfigs {
+		f.Add(config.Example)
+	}
+	for _, s := range []string{
+		"resp=\"powered by\" && is_req!=true && app=\"Meta-Author\"",
+		"(title=\"你好\" && status_code=200)||tag=\"x\"",
+		"body~=\"(<center><strong>EZCMS ([\\d\\.]+) )\"",
+		"status_code=[200 TO 299} && !(tag in (\"a\", \"b\")) || exists(title) || tag!=*",
+		"port>=\"80\" && status_code not in (1, 2)",
+	} {
+		f.Add(s)
+	}
+	title := "hello"
+	record := &matchRecord{
+		Domain:     "www.example.com",
+		StatusCode: 200,
+		Host:       &matchHost{Country: "中国"},
+		Tags:       []matchTag{{Name: "cdn", Content: "nginx"}},
+		Title:      &title,
+	}
+	policy := &Policy{MaxDepth: 3, MaxClauses: 5, MaxRegex: 1, MaxInValues: 3}
+	// 覆盖AST的所有使用方，任何输入都不应panic
+	f.Fuzz(func(t *testing.T, s string) {
+		Complete(s, -1, webSearchConfigs)
+		Complete(s, len([]rune(s))/2, webSearchConfigs)
+		rule, err := Parse(s)
+		if err != nil {
+			return
+		}
+		if filter, err := rule.ToMongo(webSearchConfigs); err == nil {
+			Optimize(filter)
+		}
+		rule.ToSQL(webSearchConfigs, DialectMySQL)
+		rule.ToSQL(webSearchConfigs, DialectClickHouse)
+		rule.Match(webSearchConfigs, record)
+		rule.Check(webSearchConfigs, policy)
+	})
+}
@@ -1,23 +1,23 @@
 package dsl
 
 import (
-	"errors"
-	"regexp"
 	"strings"
+	"unicode"
 )
 
 //	title="NBX NetSet" || (header="Alternates" && body="NBX")
-//	1||(2&&3) => 1 2 3 && ||
 //	header="X-Copyright: wspx" || header="X-Powered-By: ANSI C"
 //	header="SS_MID" && header="squarespace.net"
-//	expr = self.in2post(expr)
-//	print("后缀表达式", expr)
+//	port=[80 TO 443] && !(status_code in (404, 500))
 
 // 支持 title body header icon
-// 符号支持 && || ()
+// 符号支持 && || ! () > >= < <= [a TO b] in (a, b) not in (a, b) =* exists(tag) ~=/regex/i
 type Token struct {
 	name    string
 	content string
+	pos     int // 起始位置，字符(rune)偏移
+	end     int // 结束位置(不含)
+	flags   string
 }
 
 const (
@@ -25,164 +25,167 @@ const (
 	tokenText   = "text"
 	tokenNumber = "number"
 	tokenBool   = "bool"
+	tokenRegex  = "regex"
+	tokenEOF    = "EOF"
 
 	tokenContains   = "="
 	tokenFullEqual  = "=="
 	tokenNotEqual   = "!="
 	tokenRegexEqual = "~="
 
+	tokenGreater      = ">"
+	tokenGreaterEqual = ">="
+	tokenLess         = "<"
+	tokenLessEqual    = "<="
+
+	// 区间 port=[80 TO 443]，[]包含端点，{}不包含端点
+	tokenRangeStart = "rangeStart"
+	tokenRangeEnd   = "rangeEnd"
+	tokenRangeTo    = "TO"
+
 	tokenAnd = "&&"
 	tokenOr  = "||"
 
 	tokenLeftBracket  = "("
 	tokenRightBracket = ")"
+
+	tokenNot   = "!"
+	tokenComma = ","
+	tokenStar  = "*"
+
+	// 关键字，解析为tag后按内容识别
+	tokenIn     = "in"
+	tokenNotIn  = "not in"
+	tokenExists = "exists"
 )
 
+// Pos 返回token的起始位置
+func (t Token) Pos() int {
+	return t.pos
+}
+
+// 用于错误信息的token描述
+func (t Token) display() string {
+	switch t.name {
+	case tokenEOF:
+		return tokenEOF
+	case tokenText:
+		return `"` + t.content + `"`
+	case tokenRegex:
+		return "/" + t.content + "/" + t.flags
+	}
+	return t.content
+}
+
+// 双字符运算符，key为首字符
+var twoCharOperators = map[rune]string{
+	'=': tokenFullEqual,
+	'~': tokenRegexEqual,
+	'!': tokenNotEqual,
+	'>': tokenGreaterEqual,
+	'<': tokenLessEqual,
+	'&': tokenAnd,
+	'|': tokenOr,
+}
+
+// 单字符运算符，不能单独出现的字符不在其中
+var oneCharOperators = map[rune]string{
+	'=': tokenContains,
+	'!': tokenNot,
+	'>': tokenGreater,
+	'<': tokenLess,
+	'(': tokenLeftBracket,
+	')': tokenRightBracket,
+	',': tokenComma,
+	'*': tokenStar,
+	'[': tokenRangeStart,
+	'{': tokenRangeStart,
+	']': tokenRangeEnd,
+	'}': tokenRangeEnd,
+}
+
 func ParseTokens(s1 string) ([]Token, error) {
 	var s []rune = []rune(s1)
 	var tokens []Token
 	i := 0
-	var tmpToken Token
-	pattern := `^\w[\d\w_]*`
-	defaultReg, err := regexp.Compile(pattern)
-	if err != nil {
-		return nil, err
-	}
 	for i < len(s) {
 		x := s[i]
-		switch x {
-		case '"':
+		switch {
+		case unicode.IsSpace(x):
+			i += 1
+		case x == '"':
 			n := []rune{}
 			i2 := i + 1
-			for i2 < len(s) {
-				if s[i2] == '\\' {
-					n = append(n, s[i2+1])
+			for ; i2 < len(s) && s[i2] != '"'; i2++ {
+				if s[i2] == '\\' && i2+1 < len(s) {
 					i2 += 1
-				} else if s[i2] == '"' {
-					tmpToken = Token{
-						name:    tokenText,
-						content: string(n),
-					}
-					tokens = append(tokens, tmpToken)
-					i = i2
-					goto end
-				} else {
-					n = append(n, s[i2])
 				}
-				i2 += 1
-			}
-			return nil, errors.New("unknown text:" + string(s[i:]))
-		end:
-			i += 1
-		case '=':
-			if string(s[i:i+2]) == "==" {
-				tmpToken = Token{
-					name:    tokenFullEqual,
-					content: "==",
-				}
-				i += 1
-			} else {
-				tmpToken = Token{
-					name:    tokenContains,
-					content: "=",
-				}
-			}
-			tokens = append(tokens, tmpToken)
-			i += 1
-		case '~':
-			if string(s[i:i+2]) == "~=" {
-				tmpToken = Token{
-					name:    tokenRegexEqual,
-					content: "~=",
-				}
-				tokens = append(tokens, tmpToken)
-				i += 2
+				n = append(n, s[i2])
 			}
-		case '!':
-			if string(s[i:i+2]) == "!=" {
-				tmpToken = Token{
-					name:    tokenNotEqual,
-					content: "!=",
-				}
-				tokens = append(tokens, tmpToken)
-				i += 2
-			} else {
-				return nil, errors.New("unknown text:" + string(s[i:]))
+			if i2 >= len(s) {
+				return nil, newSyntaxError(i, `closing "`, tokenEOF)
 			}
-		case '|':
-			if string(s[i:i+2]) == "||" {
-				tmpToken = Token{
-					name:    tokenOr,
-					content: "||",
+			tokens = append(tokens, Token{name: tokenText, content: string(n), pos: i, end: i2 + 1})
+			i = i2 + 1
+		case x == '/':
+			// 正则字面量，\/表示/，其余转义原样保留给正则
+			n := []rune{}
+			i2 := i + 1
+			for ; i2 < len(s) && s[i2] != '/'; i2++ {
+				if s[i2] == '\\' && i2+1 < len(s) {
+					if s[i2+1] != '/' {
+						n = append(n, s[i2])
+					}
+					i2 += 1
 				}
-				tokens = append(tokens, tmpToken)
+				n = append(n, s[i2])
 			}
-			i += 2
-		case '&':
-			if string(s[i:i+2]) == "&&" {
-				tmpToken = Token{
-					name:    tokenAnd,
-					content: "&&",
-				}
-				tokens = append(tokens, tmpToken)
+			if i2 >= len(s) {
+				return nil, newSyntaxError(i, "closing /", tokenEOF)
 			}
-			i += 2
-		case '(':
-			tmpToken = Token{
-				name:    tokenLeftBracket,
-				content: "(",
+			i3 := i2 + 1
+			for i3 < len(s) && s[i3] >= 'a' && s[i3] <= 'z' {
+				i3 += 1
 			}
-			tokens = append(tokens, tmpToken)
-			i += 1
-		case ')':
-			tmpToken = Token{
-				name:    tokenRightBracket,
-				content: ")",
+			tokens = append(tokens, Token{name: tokenRegex, content: string(n), flags: string(s[i2+1 : i3]), pos: i, end: i3})
+			i = i3
+		case (x >= '0' && x <= '9') || x == '-':
+			i2 := i + 1
+			for i2 < len(s) && ((s[i2] >= '0' && s[i2] <= '9') || s[i2] == '-' || s[i2] == '.') {
+				i2 += 1
 			}
-			tokens = append(tokens, tmpToken)
-			i += 1
-		case ' ':
-			i += 1
-		case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9', '-':
-			tmp := []rune{s[i]}
+			tokens = append(tokens, Token{name: tokenNumber, content: string(s[i:i2]), pos: i, end: i2})
+			i = i2
+		case isIdentRune(x):
 			i2 := i + 1
-			for i2 < len(s) {
-				if (s[i2] >= '0' && s[i2] <= '9') || s[i2] == '-' {
-					tmp = append(tmp, s[i2])
-					i2 += 1
-					i += 1
-				} else {
-					break
-				}
+			for i2 < len(s) && isIdentRune(s[i2]) {
+				i2 += 1
 			}
-			tmpToken = Token{
-				name:    tokenNumber,
-				content: string(tmp),
+			ret := string(s[i:i2])
+			lowerRet := strings.ToLower(ret)
+			if lowerRet == "true" || lowerRet == "false" {
+				tokens = append(tokens, Token{name: tokenBool, content: lowerRet, pos: i, end: i2})
+			} else {
+				tokens = append(tokens, Token{name: tokenTag, content: ret, pos: i, end: i2})
 			}
-			tokens = append(tokens, tmpToken)
-			i += 1
+			i = i2
 		default:
-			textOption := string(s[i:])
-			ret := defaultReg.FindString(textOption)
-			if ret != "" {
-				lowerRet := strings.ToLower(ret)
-				if lowerRet == "true" || lowerRet == "false" {
-					tmpToken = Token{
-						name:    tokenBool,
-						content: lowerRet,
-					}
-				} else {
-					tmpToken = Token{
-						name:    tokenTag,
-						content: ret,
-					}
-				}
-				tokens = append(tokens, tmpToken)
-				i += len(ret)
-			} else {
-				return nil, errors.New("unknown text:" + string(s[i:]))
+			if op, ok := twoCharOperators[x]; ok && i+1 < len(s) && string(s[i:i+2]) == op {
+				tokens = append(tokens, Token{name: op, content: op, pos: i, end: i + 2})
+				i += 2
+				continue
+			}
+			if op, ok := oneCharOperators[x]; ok {
+				tokens = append(tokens, Token{name: op, content: string(x), pos: i, end: i + 1})
+				i += 1
+				continue
 			}
+			return nil, newSyntaxError(i, "token", string(x))
 		}
 	}
 	return tokens, nil
 }
+
+func isIdentRune(r rune) bool {
+	return r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+}
@@ -1,33 +1,42 @@
 package dsl
 
-import "errors"
-
 type tokenStream struct {
-	tokens      []Token
-	index       int
-	tokenLength int
+	tokens []Token
+	index  int
+	eof    Token
 }
 
 func newTokenStream(tokens []Token) *tokenStream {
 	ret := new(tokenStream)
 	ret.tokens = tokens
-	ret.tokenLength = len(tokens)
+	end := 0
+	if len(tokens) > 0 {
+		end = tokens[len(tokens)-1].end
+	}
+	ret.eof = Token{name: tokenEOF, pos: end, end: end}
 	return ret
 }
 
-func (this *tokenStream) rewind() {
-	this.index -= 1
+// 读取下一个token，到达末尾后一直返回EOF
+func (this *tokenStream) next() Token {
+	token := this.peek()
+	if this.index < len(this.tokens) {
+		this.index += 1
+	}
+	return token
 }
 
-func (this *tokenStream) next() (Token, error) {
+func (this *tokenStream) peek() Token {
 	if this.index >= len(this.tokens) {
-		return Token{}, errors.New("token解析失败")
+		return this.eof
 	}
-	token := this.tokens[this.index]
-	this.index += 1
-	return token, nil
+	return this.tokens[this.index]
 }
 
-func (this tokenStream) hasNext() bool {
-	return this.index < this.tokenLength
+// 查看之后第n个token，peekN(0)等同于peek
+func (this *tokenStream) peekN(n int) Token {
+	if this.index+n >= len(this.tokens) {
+		return this.eof
+	}
+	return this.tokens[this.index+n]
 }
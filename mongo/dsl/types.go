@@ -5,12 +5,20 @@ const (
 	ConfigTypeNumber = "number"
 	ConfigTypeBool   = "bool"
 	ConfigTypeBytes  = "bytes"
+	ConfigTypeDate   = "date"
 )
 
+// DefaultDateLayout ConfigTypeDate默认的日期格式
+const DefaultDateLayout = "2006-01-02"
+
 type Config struct {
-	TagName     string `json:"tag_name"`
-	ColumnName  string `json:"column_name"`
-	Type        string `json:"type"`
-	Description string `json:"description"` // 描述
-	Example     string `json:"example"`     // 用法
+	TagName     string   `json:"tag_name"`
+	ColumnName  string   `json:"column_name"`
+	Type        string   `json:"type"`
+	Layout      string   `json:"layout"`      // ConfigTypeDate的日期格式，为空时使用DefaultDateLayout
+	Regex       bool     `json:"regex"`       // 是否允许~=正则查询，只应对建有索引的字段开启
+	Enum        []string `json:"enum"`        // 可选值，用于自动补全
+	Roles       []string `json:"roles"`       // 允许查询该字段的角色，为空时不限制，见Policy
+	Description string   `json:"description"` // 描述
+	Example     string   `json:"example"`     // 用法
 }
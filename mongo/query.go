@@ -50,7 +50,7 @@ func FindOne(ctx context.Context, db *mongo.Client, dbName, collectionName strin
 	return nil
 }
 
-// 查询多条记录。
+// 查询多条记录。结果集较大时使用FindEach。
 func FindMany(ctx context.Context, db *mongo.Client, dbName, collectionName string, filter, fields, sort any, cursor, size int, results any) error {
 	ctx, cancel, coll := Prepare(ctx, db, dbName, collectionName)
 	defer cancel()
@@ -155,7 +155,7 @@ func DeleteMany(ctx context.Context, db *mongo.Client, dbName, collectionName st
 	return nil
 }
 
-// 执行Pipeline查询。
+// 执行Pipeline查询，解码第一条结果；没有结果时返回mongo.ErrNoDocuments。
 func Pipe(ctx context.Context, db *mongo.Client, dbName, collectionName string, pipeline, result any) error {
 	ctx, cancel, coll := Prepare(ctx, db, dbName, collectionName)
 	defer cancel()
@@ -166,6 +166,12 @@ func Pipe(ctx context.Context, db *mongo.Client, dbName, collectionName string,
 	}
 	defer cur.Close(ctx)
 
+	if !cur.Next(ctx) {
+		if err = cur.Err(); err != nil {
+			return err
+		}
+		return mongo.ErrNoDocuments
+	}
 	if err = cur.Decode(result); err != nil {
 		return err
 	}
@@ -173,6 +179,7 @@ func Pipe(ctx context.Context, db *mongo.Client, dbName, collectionName string,
 	return nil
 }
 
+// 执行Pipeline查询，解码全部结果。结果集较大时使用PipeEach。
 func PipeMany(ctx context.Context, db *mongo.Client, dbName, collectionName string, pipeline, result any) error {
 	ctx, cancel, coll := Prepare(ctx, db, dbName, collectionName)
 	defer cancel()
@@ -0,0 +1,107 @@
+package mongo
+
+import (
+	"context"
+
+	"github.com/hudangwei/common/cache"
+	"github.com/hudangwei/common/util/ctxutil"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	moptions "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ResumeTokenStore 持久化change stream的resume token，消费者重启后从上次的位置继续。
+type ResumeTokenStore interface {
+	// Load 返回key对应的resume token，不存在时返回nil, nil。
+	Load(key string) (bson.Raw, error)
+	Save(key string, token bson.Raw) error
+}
+
+// CacheTokenStore 基于cache.Cache的ResumeTokenStore实现。
+type CacheTokenStore struct {
+	Cache *cache.Cache
+}
+
+func NewCacheTokenStore(c *cache.Cache) *CacheTokenStore {
+	return &CacheTokenStore{Cache: c}
+}
+
+func (s *CacheTokenStore) Load(key string) (bson.Raw, error) {
+	if !s.Cache.Has(key) {
+		return nil, nil
+	}
+	v, err := s.Cache.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	return bson.Raw(v), nil
+}
+
+func (s *CacheTokenStore) Save(key string, token bson.Raw) error {
+	return s.Cache.Set(key, token)
+}
+
+type WatchModel struct {
+	CollectionName string           // 为空时监听整个数据库
+	Pipeline       any              // 过滤变更事件的pipeline，可为空
+	FullDocument   bool             // update事件是否返回变更后的完整文档
+	BatchSize      int              // 每批拉取的事件数
+	Store          ResumeTokenStore // 为空时不持久化resume token
+	StoreKey       string           // resume token在Store中的key
+}
+
+// 监听集合或数据库的变更事件，每个事件回调fn，在fn中通过cs.Decode解码事件。
+// fn成功返回后保存该事件的resume token；fn返回错误时中止监听并返回该错误，
+// 该事件的token不会保存，重启后会被再次投递。
+// 监听会一直阻塞，直到ctx取消或出错。
+func Watch(ctx context.Context, db *mongo.Client, dbName string, model WatchModel, fn func(cs *mongo.ChangeStream) error) error {
+	ctx = ctxutil.Ensure(ctx)
+
+	mopts := moptions.ChangeStream()
+	if model.FullDocument {
+		mopts.SetFullDocument(moptions.UpdateLookup)
+	}
+	if model.BatchSize > 0 {
+		mopts.SetBatchSize(int32(model.BatchSize))
+	}
+	if model.Store != nil {
+		token, err := model.Store.Load(model.StoreKey)
+		if err != nil {
+			return err
+		}
+		if token != nil {
+			mopts.SetResumeAfter(token)
+		}
+	}
+
+	pipeline := model.Pipeline
+	if pipeline == nil {
+		pipeline = mongo.Pipeline{}
+	}
+
+	var cs *mongo.ChangeStream
+	var err error
+	database := db.Database(dbName)
+	if model.CollectionName == "" {
+		cs, err = database.Watch(ctx, pipeline, mopts)
+	} else {
+		cs, err = database.Collection(model.CollectionName).Watch(ctx, pipeline, mopts)
+	}
+	if err != nil {
+		return err
+	}
+	defer cs.Close(context.Background())
+
+	for cs.Next(ctx) {
+		if err := fn(cs); err != nil {
+			return err
+		}
+		if model.Store != nil {
+			if err := model.Store.Save(model.StoreKey, cs.ResumeToken()); err != nil {
+				return err
+			}
+		}
+	}
+
+	return cs.Err()
+}
@@ -0,0 +1,32 @@
+package mongo
+
+import (
+	"testing"
+
+	"github.com/hudangwei/common/cache"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TestCacheTokenStore(t *testing.T) {
+	c, err := cache.NewCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	s := NewCacheTokenStore(c)
+
+	if token, err := s.Load("orders"); token != nil || err != nil {
+		t.Fatalf("missing: %v, %v", token, err)
+	}
+	want, _ := bson.Marshal(bson.M{"_data": "8263"})
+	if err := s.Save("orders", want); err != nil {
+		t.Fatal(err)
+	}
+	token, err := s.Load("orders")
+	if err != nil || string(token) != string(want) || token.Lookup("_data").StringValue() != "8263" {
+		t.Fatalf("got %v, %v", token, err)
+	}
+	if token, _ := s.Load("users"); token != nil {
+		t.Errorf("other key: %v", token)
+	}
+}
@@ -1,19 +1,29 @@
+// Package codec 保留原有的导入路径，实现已移至binding/codec
 package codec
 
-import "net/http"
+import (
+	"net/http"
 
-type Interface interface {
-	ContentType() []string
-	Marshal(interface{}) ([]byte, error)
-}
+	"github.com/hudangwei/common/binding/codec"
+)
 
-type Direct interface {
-	Interface
-	Unmarshal(*http.Request, interface{}) error
-}
+type Interface = codec.Interface
+type Direct = codec.Direct
+type SearchMap = codec.SearchMap
+type Search = codec.Search
+type FileSearch = codec.FileSearch
+type UploadedFile = codec.UploadedFile
+type Files = codec.Files
+type Values = codec.Values
+
+type Json = codec.Json
+type MultipartForm = codec.MultipartForm
+type Empty = codec.Empty
+type Form = codec.Form
+type Xml = codec.Xml
+type Protobuf = codec.Protobuf
+type Msgpack = codec.Msgpack
 
-type SearchMap map[string][]byte
-type Search interface {
-	Interface
-	UnmarshalSearchMap(*http.Request) (SearchMap, error)
+func CopyBody(req *http.Request) ([]byte, error) {
+	return codec.CopyBody(req)
 }